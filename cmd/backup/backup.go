@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:  "backup",
+	Usage: "Back up virtual machines and containers with vzdump",
+	Description: "One vzdump task is started per node, backing up that node's guests in turn.\n\n" +
+		util.GuestsArgUsage,
+	UsageText: "gomox [-w] backup [options] <GUEST>...",
+	Action:    backup,
+	Flags:     util.VzdumpFlags(),
+}
+
+func backup(c *cli.Context) error {
+	opts, err := util.GetVzdumpOptions(c)
+	if err != nil {
+		return err
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	guests, err := util.ResolveGuestArgs(c.Context, client, c.Args().Slice(), false)
+	if err != nil {
+		return err
+	}
+
+	// vzdump backs up the guests of one node at a time anyway
+	var nodes []string
+	vmids := map[string][]uint64{}
+	for _, rs := range guests {
+		if _, ok := vmids[rs.Node]; !ok {
+			nodes = append(nodes, rs.Node)
+		}
+		vmids[rs.Node] = append(vmids[rs.Node], rs.VMID)
+	}
+	var ts []*proxmox.Task
+	for _, node := range nodes {
+		task, err := util.StartBackup(c.Context, &client, node, vmids[node], opts)
+		if err != nil {
+			for _, started := range ts {
+				logrus.Warnf("Backup task %s was already started\n", started.UPID)
+			}
+			return fmt.Errorf("node %s: %w", node, err)
+		}
+		logrus.Infof("Backup requested! (node: %s, guests: %v, task: %s)\n", node, vmids[node], task.UPID)
+		ts = append(ts, task)
+	}
+
+	if len(ts) > 1 {
+		return taskstatus.FinishCliTasks(c, ts)
+	}
+	return taskstatus.FinishCliTask(c, ts[0])
+}
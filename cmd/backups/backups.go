@@ -0,0 +1,70 @@
+package backups
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/output"
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:  "backups",
+	Usage: "List the backups of a virtual machine or container on every backup storage",
+	Description: util.GuestArgUsage + " The VMID of a destroyed guest finds its backups, too.\n\n" +
+		"Restore a backup with `gomox restore <VOLID>`.",
+	UsageText: "gomox backups <GUEST>",
+	Action:    listBackups,
+}
+
+func listBackups(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	vmid, err := getVmid(c, client, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	locations, err := util.FindStorages(c.Context, client, "", func(rs *proxmox.ClusterResource) bool {
+		return util.HasContent(rs, util.ContentBackup)
+	})
+	if err != nil {
+		return err
+	}
+	if len(locations) == 0 {
+		return fmt.Errorf("no storage for backups is available")
+	}
+	logrus.Debugf("searching %d storages for backups of guest %d\n", len(locations), vmid)
+
+	filter := util.ContentFilter{Content: util.ContentBackup, VMID: vmid}
+	volumes, listErr := util.ListContentAt(c.Context, client, locations, filter)
+	if err := output.Print(c, volumes); err != nil {
+		return err
+	}
+	return listErr
+}
+
+// getVmid resolves the guest ref, or takes it as the VMID of a guest that
+// no longer exists.
+func getVmid(c *cli.Context, client proxmox.Client, ref string) (uint64, error) {
+	rs, err := util.ResolveGuest(c.Context, client, ref)
+	var noGuest *util.NoGuestError
+	if errors.As(err, &noGuest) {
+		if vmid, parseErr := strconv.ParseUint(ref, 10, 64); parseErr == nil {
+			return vmid, nil
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rs.VMID, nil
+}
@@ -1,9 +1,10 @@
 package clone
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/output"
 	"github.com/perchnet/gomox/tasks"
 	"github.com/perchnet/gomox/util"
 	"github.com/luthermonson/go-proxmox"
@@ -13,9 +14,11 @@ import (
 
 //goland:noinspection SpellCheckingInspection
 var Command = &cli.Command{
-	Name:   "clone",
-	Usage:  "Clone a virtual machine.",
-	Action: cloneVm,
+	Name:        "clone",
+	Usage:       "Clone a virtual machine.",
+	UsageText:   "gomox clone [options] <GUEST> [NEWID]",
+	Description: util.GuestArgUsage,
+	Action:      cloneVm,
 	Flags: []cli.Flag{
 		&cli.Uint64Flag{
 			Name:        "newid",
@@ -52,9 +55,10 @@ var Command = &cli.Command{
 			Category: "Cloned VM Options:",
 		},
 		&cli.StringFlag{
-			Name:     "pool",
-			Usage:    "Add the new VM to the specified pool.",
-			Category: "Cloned VM Options:",
+			Name:        "pool",
+			Usage:       "Add the new VM to the specified pool.",
+			Category:    "Cloned VM Options:",
+			DefaultText: "the profile's default pool",
 		},
 		&cli.StringFlag{
 			Name:     "snapname",
@@ -73,12 +77,19 @@ var Command = &cli.Command{
 		},
 		&cli.BoolFlag{
 			Name:     "overwrite",
-			Usage:    "Overwrite the target VMID if it already exists. (Note: only relevant when manually specifying VMID.)",
+			Usage:    "Overwrite the target VMID if it already exists, stopping it if it is running. (Note: only relevant when manually specifying VMID.)",
 			Category: "Cloned VM Options:",
 		},
 	},
 }
 
+// cloneResult is the output of the clone command.
+type cloneResult struct {
+	NewID  int          `json:"newid" table:"New VMID"`
+	UPID   proxmox.UPID `json:"upid" table:"UPID"`
+	Status string       `json:"status"`
+}
+
 func bool2uint8(b bool) uint8 {
 	if b {
 		return 1
@@ -90,21 +101,12 @@ func bool2uint8(b bool) uint8 {
 func cloneVm(c *cli.Context) error {
 	newId := c.Uint64("newid")
 
-	client := util.InstantiateClient(
-		util.GetPveUrl(c),
-		proxmox.Credentials{
-			Username: c.String("pveuser"),
-			Password: c.String("pvepassword"),
-			Realm:    c.String("pverealm"),
-		},
-	)
-
-	vmid, err := util.GetVmidArg(c.Args().Slice())
+	client, err := util.GetClient(c)
 	if err != nil {
 		return err
 	}
 
-	vm, err := util.GetVirtualMachineByVMID(c.Context, vmid, client)
+	vm, err := util.GetVirtualMachineArg(c.Context, client, c.Args().Slice())
 	if err != nil {
 		return err
 	}
@@ -114,60 +116,36 @@ func cloneVm(c *cli.Context) error {
 			newId = uint64(newIdT)
 		}
 	}
+	pool := c.String("pool")
+	if !c.IsSet("pool") {
+		pool = util.GetDefaultPool(c)
+	}
 	cloneOptions := proxmox.VirtualMachineCloneOptions{
 		NewID:    int(newId),
 		BWLimit:  c.Uint64("bwlimit"),
 		Full:     bool2uint8(c.Bool("full")),
 		Name:     c.String("name"),
-		Pool:     c.String("pool"),
+		Pool:     pool,
 		SnapName: c.String("snapname"),
 		Storage:  c.String("storage"),
 		Target:   c.String("target"),
 	}
 
 	if newId != 0 { // if we're manually assigning the target VMID
-		vmWithSameId, _ := util.GetVirtualMachineByVMID(
-			c.Context,
-			newId,
-			client,
-		) // check if VM already exists with target VMID
-		if vmWithSameId != nil {
-			logrus.Infof("Virtual machine with target ID %d already exists.\n", newId)
-			switch c.Bool("overwrite") {
-			case true:
-				task, err := util.DestroyVm(c.Context, vmWithSameId)
-				if err != nil {
-					return err
-				}
-				logrus.Info("overwrite requested\n")
-				logrus.Warnf("destroying VM %d (%s)...\n", vmWithSameId.VMID, vmWithSameId.Name)
-				logrus.Debugf("task: %s\n", task.UPID)
-
-				// err = tasks.WaitTask(c.Context, task, tasks.WithSpinner())
-				err = taskstatus.WaitForCliTask(c, &task)
-				if err != nil {
-					return err
-				}
-
-				logrus.Debugf("task: %s\n", task.UPID)
-			case false:
-				logrus.Tracef("%#v\n", vmWithSameId)
-				return fmt.Errorf(
-					"Use --overwrite if necessary.\n",
-				)
-			}
-
+		// check if a guest already exists with target VMID
+		if _, err := util.OverwriteGuest(c, &client, newId); err != nil {
+			return err
 		}
 	}
 
 	newVmid, task, err := vm.Clone(c.Context, &cloneOptions) // do the clone
 	if err != nil {
-		return err
+		return util.WrapApiError(err)
 	}
 
 	err = task.Ping(c.Context) // update task
 	if err != nil {
-		return err
+		return util.WrapApiError(err)
 	}
 	if newVmid == 0 {
 		newVmid = cloneOptions.NewID
@@ -175,14 +153,19 @@ func cloneVm(c *cli.Context) error {
 
 	logrus.Infof("clone requested! new id: %d.\n", newVmid)
 	logrus.Tracef("%#v\n", task)
+	var waitErr error
 	if c.Bool("wait") {
-		err = taskstatus.WaitForCliTask(c, task)
-		if err != nil {
-			return err
+		waitErr = taskstatus.WaitForCliTask(c, task)
+		if waitErr != nil && !errors.Is(waitErr, tasks.ErrTaskFailed) {
+			return waitErr
 		}
 	} else {
 		logrus.Info(tasks.GetWaitCmd(*task))
 	}
 
-	return nil
+	err = output.Print(c, cloneResult{NewID: newVmid, UPID: task.UPID, Status: task.Status})
+	if err != nil {
+		return err
+	}
+	return waitErr
 }
@@ -1,69 +1,57 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
-	"strings"
 
+	"github.com/perchnet/gomox/output"
 	"github.com/perchnet/gomox/util"
-	"github.com/jedib0t/go-pretty/v6/table"
-	"github.com/luthermonson/go-proxmox"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
 
 var Command = &cli.Command{
-	Name:   "config",
-	Usage:  "List the config settings of a Virtual Machine",
-	Action: pveVersion,
-	Flags:  []cli.Flag{},
+	Name:        "config",
+	Usage:       "List the config settings of a Virtual Machine",
+	UsageText:   "gomox config <GUEST>",
+	Description: util.GuestArgUsage,
+	Action:      pveVersion,
+	Flags:       []cli.Flag{},
 }
 
 func pveVersion(c *cli.Context) error {
-	client := util.InstantiateClient(
-		util.GetPveUrl(c),
-		proxmox.Credentials{
-			Username: c.String("pveuser"),
-			Password: c.String("pvepassword"),
-			Realm:    c.String("pverealm"),
-		},
-	)
-
-	vmid, err := util.GetVmidArg(c.Args().Slice())
+	client, err := util.GetClient(c)
 	if err != nil {
 		return err
 	}
 
-	vm, err := util.GetVirtualMachineByVMID(c.Context, uint64(vmid), client)
+	vm, err := util.GetVirtualMachineArg(c.Context, client, c.Args().Slice())
 	if err != nil {
 		return err
 	}
 
-	// simple table with zero customizations
-	tw := table.NewWriter()
-	// append a header row
-	tw.AppendHeader(table.Row{fmt.Sprintf("vm: %d", vmid), fmt.Sprintf("node: %s", vm.Node)})
-	sets := make(map[string]*json.RawMessage)
+	logrus.Infof("vm: %d, node: %s\n", vm.VMID, vm.Node)
+	// round-trip through JSON to get only the settings that are set
+	sets := make(map[string]interface{})
 	jThing, err := json.Marshal(vm.VirtualMachineConfig)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(jThing, &sets)
+	dec := json.NewDecoder(bytes.NewReader(jThing))
+	dec.UseNumber() // keep numbers as written instead of float64
+	err = dec.Decode(&sets)
 	if err != nil {
 		return err
 	}
 	for k, v := range sets {
-		s := strings.Trim(string(*v), "\"")
-		// append some data rows.
-		tw.AppendRow(
-			table.Row{
-				k, s,
-			},
-		)
-
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				sets[k] = i
+			} else if f, err := n.Float64(); err == nil {
+				sets[k] = f
+			}
+		}
 	}
 
-	logrus.Infof("\n" + tw.Render())
-	// fmt.Println(tw.Render())
-	return nil
+	return output.Print(c, sets)
 }
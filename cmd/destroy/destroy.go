@@ -1,18 +1,23 @@
 package destroy
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
-	"github.com/perchnet/gomox/util"
 	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/util"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
 
 var Command = &cli.Command{
-	Name:   "destroy",
-	Usage:  "Delete a virtual machine",
-	Action: destroyVmCmd,
+	Name:        "destroy",
+	Usage:       "Delete a virtual machine",
+	UsageText:   "gomox destroy [options] <GUEST>...",
+	Description: util.GuestsArgUsage,
+	Action:      destroyVmCmd,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
 			Name:  "force",
@@ -23,77 +28,60 @@ var Command = &cli.Command{
 			Usage: "Don't return error if VM is already in requested state",
 			Value: false,
 		},
+		util.ParallelFlag(),
 	},
 }
 
 func destroyVmCmd(c *cli.Context) error {
-	client := util.InstantiateClient(
-		util.GetPveUrl(c),
-		proxmox.Credentials{
-			Username: c.String("pveuser"),
-			Password: c.String("pvepassword"),
-			Realm:    c.String("pverealm"),
-		},
-	)
-
-	vmid, err := util.GetVmidArg(c.Args().Slice())
+	client, err := util.GetClient(c)
 	if err != nil {
 		return err
 	}
 
-	vm, err := util.GetVirtualMachineByVMID(c.Context, vmid, client)
+	// with --idempotent, guests that are already gone are fine
+	guests, err := util.ResolveGuestArgs(c.Context, client, c.Args().Slice(), c.Bool("idempotent"))
 	if err != nil {
-		// if we receive an error
-		msg := fmt.Sprintf(
-			"Could not destroy VM %d.\n"+
-				"%#v", vmid, err,
-		)
-		switch c.Bool("idempotent") {
-		case true:
-			logrus.Warn(msg)
-			return nil
-		case false:
-			logrus.Panic(msg)
-			// don't need to return because the panic will return for us
-		}
+		return fmt.Errorf("Could not destroy %s.\n%s", strings.Join(c.Args().Slice(), " "), err)
+	}
+	action := destroyAction(c.Bool("force"))
+	switch len(guests) {
+	case 0:
+		return nil
+	case 1:
+	default:
+		return util.RunBulkCli(c, client, guests, action)
 	}
-	if vm.IsStopped() {
-		task, err := util.DestroyVm(c.Context, vm)
-		if err != nil {
-			return err
-		}
-		logrus.Infof(
-			"Deletion requested!\n"+
-				"%#v", task,
-		)
-		if c.Bool("wait") {
 
+	vm, err := util.GetVirtualMachine(c.Context, client, guests[0])
+	if err != nil {
+		return err
+	}
+	task, err := action(c.Context, vm)
+	if err != nil {
+		return err
+	}
+	logrus.Info("Deletion requested!\n")
+	return taskstatus.FinishCliTask(c, task)
+}
+
+// destroyAction deletes a stopped VM, stopping it first if force is set.
+func destroyAction(force bool) util.GuestAction {
+	return func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		if vm.IsStopped() {
+			task, err := util.DestroyVm(ctx, vm)
+			return &task, err
 		}
-		return nil
-	} else {
-		if c.Bool("force") {
-			logrus.Warnf(
+		if !force {
+			return nil, fmt.Errorf(
 				"VM %d is currently %s!\n"+
-					"Requesting stop.", vmid, vm.Status,
-			)
-			task, err := util.RequestState(
-				c.Context,
-				util.StateRequestParams{RequestedState: util.StoppedState, Vm: vm},
-			)
-			if err != nil {
-				return err
-			} else {
-				logrus.Infof(
-					"Stop requested!\n"+
-						"%#v", task,
-				)
-			}
-		} else {
-			err = fmt.Errorf(
-				"VM %d is currently %s!\n"+
-					"Stop it first, or use `--force`.", vmid, vm.Status,
+					"Stop it first, or use `--force`.", vm.VMID, vm.Status,
 			)
 		}
+		logrus.Warnf(
+			"VM %d is currently %s!\n"+
+				"Requesting stop.", vm.VMID, vm.Status,
+		)
+		task, err := util.DestroyVmWithForce(ctx, vm)
+		return &task, err
 	}
-	return err
 }
@@ -3,22 +3,31 @@ package list
 import (
 	"fmt"
 
-	"github.com/jedib0t/go-pretty/v6/table"
-	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/output"
 	"github.com/perchnet/gomox/util"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	Byte     = 1
-	Kilobyte = 1024 * Byte
-	Megabyte = 1024 * Kilobyte
-	Gigabyte = 1024 * Megabyte
-)
+// vmRow is a row of the list output.
+type vmRow struct {
+	VMID    uint64       `json:"vmid" table:"VMID"`
+	Type    string       `json:"type"`
+	Name    string       `json:"name"`
+	Node    string       `json:"node"`
+	Status  string       `json:"status"`
+	MaxMem  output.Bytes `json:"maxmem" table:"Mem"`
+	MaxDisk output.Bytes `json:"maxdisk" table:"BootDisk"`
+}
+
+// detailRow is a row of the list output with --details.
+type detailRow struct {
+	vmRow
+	PID uint64 `json:"pid" table:"PID"`
+}
 
 var Command = &cli.Command{
 	Name:   "list",
-	Usage:  "Lists virtual machines",
+	Usage:  "Lists virtual machines and containers",
 	Action: list,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
@@ -37,43 +46,84 @@ var Command = &cli.Command{
 			TakesFile:   false,
 			Action:      nil,
 		},
+		&cli.StringFlag{
+			Name:  "sort-by",
+			Usage: "sort by `COLUMN`, e.g. name, node, status or mem",
+			Value: "vmid",
+		},
+		&cli.BoolFlag{
+			Name:  "reverse",
+			Usage: "reverse the sort order",
+		},
+		&cli.BoolFlag{
+			Name:  "details",
+			Usage: "fetch each guest's status for the PID column (one request per guest)",
+		},
+		util.ParallelFlag(),
 	},
 }
 
+func init() {
+	Command.Flags = append(Command.Flags, util.ResourceFilterFlags()...)
+}
+
 func list(c *cli.Context) error {
-	client := util.InstantiateClient(
-		util.GetPveUrl(c),
-		proxmox.Credentials{
-			Username: c.String("pveuser"),
-			Password: c.String("pvepassword"),
-			Realm:    c.String("pverealm"),
-		},
-	)
-	rsList, err := util.GetVirtualMachineList(c.Context, client, util.QemuResource)
+	filter, err := util.GetResourceFilter(c)
 	if err != nil {
 		return err
 	}
-	// simple table with zero customizations
-	tw := table.NewWriter()
-	// append a header row
-	tw.AppendHeader(table.Row{"VMID", "Name", "Status", "Mem (MB)", "BootDisk (GB)", "PID"})
-	// append some data rows
-
-	for _, vm := range rsList {
-		// if vm.
+	opts := []util.GetResourceListOption{util.WithFilter(filter)}
+	switch guestType := c.String("type"); guestType {
+	case "both", "":
+	case util.QemuResource:
+		opts = append(opts, util.WithQemu())
+	case util.LxcResource:
+		opts = append(opts, util.WithLxc())
+	default:
+		return fmt.Errorf("unknown type %q, use qemu, lxc or both", guestType)
+	}
 
-		tw.AppendRow(
-			table.Row{
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	resources, err := util.GetGuestList(c.Context, client, opts...)
+	if err != nil {
+		return err
+	}
+	rows := []vmRow{}
+	for _, rs := range resources {
+		rows = append(
+			rows, vmRow{
 				// vmid,name,status,mem,boot,pid
 				// https://git.proxmox.com/?p=qemu-server.git;a=blob;f=PVE/CLI/qm.pm;h=b17b4fe25d5bd21e9fe188e82998972b1dc29c36;hb=HEAD#l1001
-				int(vm.VMID), vm.Name, vm.Status, vm.MaxMem / Megabyte,
-				float64(vm.MaxDisk) / float64(Gigabyte),
-				uint64(vm.PID),
+				VMID:    rs.VMID,
+				Type:    rs.Type,
+				Name:    rs.Name,
+				Node:    rs.Node,
+				Status:  rs.Status,
+				MaxMem:  output.Bytes(rs.MaxMem),
+				MaxDisk: output.Bytes(rs.MaxDisk),
 			},
 		)
 	}
-	tw.Style().Options = table.OptionsNoBordersAndSeparators
+	if !c.Bool("details") {
+		if err := output.Sort(rows, c.String("sort-by"), c.Bool("reverse")); err != nil {
+			return err
+		}
+		return output.Print(c, rows)
+	}
 
-	fmt.Println(tw.Render())
-	return nil
+	pids, err := util.GetGuestPIDs(c.Context, client, resources, c.Int("parallel"))
+	if err != nil {
+		return err
+	}
+	detailRows := make([]detailRow, len(rows))
+	for i, row := range rows {
+		detailRows[i] = detailRow{vmRow: row, PID: pids[row.VMID]}
+	}
+	if err := output.Sort(detailRows, c.String("sort-by"), c.Bool("reverse")); err != nil {
+		return err
+	}
+	return output.Print(c, detailRows)
 }
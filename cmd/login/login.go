@@ -0,0 +1,50 @@
+package login
+
+import (
+	"fmt"
+
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:  "login",
+	Usage: "Log in and cache the authentication ticket for later commands",
+	UsageText: "gomox [-u USER] [-r REALM] login\n" +
+		"The password and two-factor code are read from --pvepassword/PVE_PASSWORD\n" +
+		"and --otp/PVE_OTP, or prompted for.",
+	Action: login,
+}
+
+func login(c *cli.Context) error {
+	if c.String("token-id") != "" || c.String("token-secret") != "" {
+		return fmt.Errorf("API tokens don't need a login, unset --token-id/--token-secret to log in with a password")
+	}
+	if c.String("pveuser") == "" {
+		return fmt.Errorf("no username supplied (--pveuser/PVE_USER)")
+	}
+	if c.String("pvepassword") == "" {
+		password, err := util.PromptSecret(fmt.Sprintf("Password for %s: ", util.GetUserId(c)))
+		if err != nil {
+			return fmt.Errorf("no password supplied (--pvepassword/PVE_PASSWORD): %w", err)
+		}
+		if err := c.Set("pvepassword", password); err != nil {
+			return err
+		}
+	}
+
+	httpClient, err := util.GetHttpClient(c)
+	if err != nil {
+		return err
+	}
+	ticket, err := util.Login(c, httpClient)
+	if err != nil {
+		return err
+	}
+	logrus.Infof(
+		"logged in as %s, ticket valid until %s\n",
+		ticket.Username, ticket.Expires().Format("15:04:05"),
+	)
+	return nil
+}
@@ -0,0 +1,39 @@
+package logout
+
+import (
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:   "logout",
+	Usage:  "Forget the cached authentication ticket",
+	Action: logout,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Forget the cached tickets of every profile, server, and user",
+		},
+	},
+}
+
+func logout(c *cli.Context) error {
+	if c.Bool("all") {
+		if err := util.ForgetAllTickets(); err != nil {
+			return err
+		}
+		logrus.Info("forgot all cached tickets\n")
+		return nil
+	}
+	forgotten, err := util.ForgetTicket(c)
+	if err != nil {
+		return err
+	}
+	if forgotten {
+		logrus.Infof("logged out %s\n", util.GetUserId(c))
+	} else {
+		logrus.Infof("no cached ticket for %s\n", util.GetUserId(c))
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package profile
+
+import (
+	"fmt"
+
+	"github.com/perchnet/gomox/output"
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const redacted = "<redacted>"
+
+var Command = &cli.Command{
+	Name:  util.ProfileCommandName,
+	Usage: "Manage named connection profiles",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List profiles",
+			Action: listProfiles,
+		},
+		{
+			Name:      "use",
+			Usage:     "Make a profile the default",
+			UsageText: "gomox profile use <NAME>",
+			Action:    useProfile,
+		},
+		{
+			Name:      "show",
+			Usage:     "Show a profile's settings",
+			UsageText: "gomox profile show [--show-secrets] [NAME]",
+			Action:    showProfile,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "show-secrets",
+					Usage: "Print the token secret instead of redacting it",
+				},
+			},
+		},
+		{
+			Name:      "add",
+			Usage:     "Add (or with --force, replace) a profile",
+			UsageText: "gomox profile add --url URL [options] <NAME>",
+			Action:    addProfile,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "url", Usage: "Proxmox VE API `URL` (https://host:8006/api2/json)"},
+				&cli.StringFlag{Name: "user", Usage: "Proxmox VE username"},
+				&cli.StringFlag{Name: "realm", Usage: "Proxmox VE authentication realm"},
+				&cli.StringFlag{Name: "token-id", Usage: "API token ID (`user@realm!tokenname`)"},
+				&cli.StringFlag{Name: "token-secret", Usage: "API token secret"},
+				&cli.BoolFlag{Name: "insecure", Usage: "Skip TLS certificate verification", Category: "tls"},
+				&cli.StringFlag{Name: "ca-file", Usage: "PEM CA bundle `FILE`", TakesFile: true, Category: "tls"},
+				&cli.StringFlag{Name: "fingerprint", Usage: "SHA-256 certificate `FINGERPRINT`", Category: "tls"},
+				&cli.StringFlag{Name: "node", Usage: "Default `NODE`"},
+				&cli.StringFlag{Name: "pool", Usage: "Default `POOL`"},
+				&cli.BoolFlag{Name: "use", Usage: "Also make this the default profile"},
+				&cli.BoolFlag{Name: "force", Usage: "Replace the profile if it already exists"},
+			},
+		},
+		{
+			Name:      "remove",
+			Aliases:   []string{"rm"},
+			Usage:     "Remove a profile",
+			UsageText: "gomox profile remove <NAME>",
+			Action:    removeProfile,
+		},
+	},
+}
+
+func getNameArg(c *cli.Context) (string, error) {
+	if c.Args().Len() != 1 {
+		return "", fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	return c.Args().First(), nil
+}
+
+// currentMarker marks the default profile in tables.
+type currentMarker bool
+
+func (m currentMarker) String() string {
+	if m {
+		return "*"
+	}
+	return ""
+}
+
+// profileRow is a row of the profile list output.
+type profileRow struct {
+	Current currentMarker `json:"current" table:" "`
+	Name    string        `json:"name"`
+	Url     string        `json:"url" table:"URL"`
+	User    string        `json:"user"`
+	Auth    string        `json:"auth"`
+}
+
+func listProfiles(c *cli.Context) error {
+	cfg, err := util.LoadConfig(util.GetConfigPath(c))
+	if err != nil {
+		return err
+	}
+	rows := []profileRow{}
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+		row := profileRow{
+			Current: name == cfg.Current,
+			Name:    name,
+			Url:     p.Url,
+			User:    p.User,
+			Auth:    string(util.PasswordAuth),
+		}
+		if p.TokenId != "" {
+			row.User, row.Auth = p.TokenId, string(util.TokenAuth)
+		}
+		rows = append(rows, row)
+	}
+	return output.Print(c, rows)
+}
+
+func useProfile(c *cli.Context) error {
+	name, err := getNameArg(c)
+	if err != nil {
+		return err
+	}
+	path := util.GetConfigPath(c)
+	cfg, err := util.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.GetProfile(name); err != nil {
+		return err
+	}
+	cfg.Current = name
+	if err := cfg.Save(path); err != nil {
+		return err
+	}
+	logrus.Infof("now using profile %s\n", name)
+	return nil
+}
+
+func showProfile(c *cli.Context) error {
+	path := util.GetConfigPath(c)
+	cfg, err := util.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	name := c.Args().First()
+	if name == "" {
+		name = cfg.Current
+	}
+	if name == "" {
+		return fmt.Errorf("no profile given and no default profile set")
+	}
+	profile, err := cfg.GetProfile(name)
+	if err != nil {
+		return err
+	}
+	shown := *profile
+	if shown.TokenSecret != "" && !c.Bool("show-secrets") {
+		shown.TokenSecret = redacted
+	}
+	logrus.Infof("profile: %s\n", name)
+	return output.Print(c, shown)
+}
+
+func addProfile(c *cli.Context) error {
+	name, err := getNameArg(c)
+	if err != nil {
+		return err
+	}
+	path := util.GetConfigPath(c)
+	cfg, err := util.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	if _, exists := cfg.Profiles[name]; exists && !c.Bool("force") {
+		return fmt.Errorf("profile %s already exists, use --force to replace it", name)
+	}
+	profile := &util.Profile{
+		Url:         c.String("url"),
+		User:        c.String("user"),
+		Realm:       c.String("realm"),
+		TokenId:     c.String("token-id"),
+		TokenSecret: c.String("token-secret"),
+		Insecure:    c.Bool("insecure"),
+		CaFile:      c.String("ca-file"),
+		Fingerprint: c.String("fingerprint"),
+		Node:        c.String("node"),
+		Pool:        c.String("pool"),
+	}
+	if profile.Url == "" {
+		return fmt.Errorf("a profile needs at least a --url")
+	}
+	if profile.TokenId != "" || profile.TokenSecret != "" {
+		if err := util.CheckToken(profile.TokenId, profile.TokenSecret); err != nil {
+			return err
+		}
+	}
+	if profile.Fingerprint != "" {
+		profile.Fingerprint, err = util.NormalizeFingerprint(profile.Fingerprint)
+		if err != nil {
+			return err
+		}
+	}
+	cfg.Profiles[name] = profile
+	if c.Bool("use") || cfg.Current == "" {
+		cfg.Current = name
+	}
+	if err := cfg.Save(path); err != nil {
+		return err
+	}
+	logrus.Infof("saved profile %s to %s\n", name, path)
+	return nil
+}
+
+func removeProfile(c *cli.Context) error {
+	name, err := getNameArg(c)
+	if err != nil {
+		return err
+	}
+	path := util.GetConfigPath(c)
+	cfg, err := util.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.GetProfile(name); err != nil {
+		return err
+	}
+	delete(cfg.Profiles, name)
+	if cfg.Current == name {
+		cfg.Current = ""
+	}
+	if err := cfg.Save(path); err != nil {
+		return err
+	}
+	logrus.Infof("removed profile %s\n", name)
+	return nil
+}
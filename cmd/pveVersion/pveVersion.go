@@ -1,9 +1,8 @@
 package pveVersion
 
 import (
+	"github.com/perchnet/gomox/output"
 	"github.com/perchnet/gomox/util"
-	"github.com/luthermonson/go-proxmox"
-	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
 
@@ -15,20 +14,15 @@ var Command = &cli.Command{
 }
 
 func pveVersion(c *cli.Context) error {
-	client := util.InstantiateClient(
-		util.GetPveUrl(c),
-		proxmox.Credentials{
-			Username: c.String("pveuser"),
-			Password: c.String("pvepassword"),
-			Realm:    c.String("pverealm"),
-		},
-	)
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
 
 	version, err := client.Version(c.Context)
 	if err != nil {
-		return err
+		return util.WrapApiError(err)
 	}
 
-	logrus.Info(version.Release)
-	return nil
+	return output.Print(c, version)
 }
@@ -0,0 +1,24 @@
+package reboot
+
+import (
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/util"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "reboot",
+	Usage:       "Reboot a virtual machine cleanly, through the guest OS (ACPI)",
+	UsageText:   "gomox reboot [options] <GUEST>...",
+	Description: util.GuestsArgUsage,
+	Action:      rebootVm,
+	Flags:       util.StateFlags(),
+}
+
+func rebootVm(c *cli.Context) error {
+	task, err := util.RequestStateCli(c, util.StateRequestParams{RequestedState: util.RebootState})
+	if err != nil || task == nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
@@ -0,0 +1,24 @@
+package reset
+
+import (
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/util"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "reset",
+	Usage:       "Reset a virtual machine (hard reboot, without the guest OS)",
+	UsageText:   "gomox reset [options] <GUEST>...",
+	Description: util.GuestsArgUsage,
+	Action:      resetVm,
+	Flags:       util.StateFlags(),
+}
+
+func resetVm(c *cli.Context) error {
+	task, err := util.RequestStateCli(c, util.StateRequestParams{RequestedState: util.ResetState})
+	if err != nil || task == nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
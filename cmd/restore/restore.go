@@ -0,0 +1,148 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/output"
+	"github.com/perchnet/gomox/tasks"
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:  "restore",
+	Usage: "Restore a virtual machine or container from a backup archive",
+	Description: "VOLID is the volume ID of a vzdump or Proxmox Backup Server archive, " +
+		"e.g. nfs:backup/vzdump-qemu-100-2026_10_01-01_00_00.vma.zst. " +
+		"With --wait, the restore's log is printed as it is written.",
+	UsageText: "gomox restore [options] <VOLID>",
+	Action:    restore,
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:        "vmid",
+			Usage:       "restore as guest `VMID`",
+			DefaultText: "next available",
+		},
+		&cli.StringFlag{
+			Name:  "storage",
+			Usage: "put the disks on `STORAGE` instead of where they were backed up from",
+		},
+		&cli.StringFlag{
+			Name:        "node",
+			Usage:       "restore on `NODE`",
+			DefaultText: "the profile's node, or one that has the archive's storage",
+		},
+		&cli.BoolFlag{
+			Name:  "unique",
+			Usage: "give the guest new MAC addresses and other unique properties",
+		},
+		&cli.BoolFlag{
+			Name:  "start",
+			Usage: "start the guest once it is restored",
+		},
+		&cli.BoolFlag{
+			Name:  "overwrite",
+			Usage: "destroy the guest with the target VMID first, if it exists, stopping it if it is running",
+		},
+	},
+}
+
+type restoreResult struct {
+	VMID       uint64       `json:"vmid" table:"VMID"`
+	Type       string       `json:"type"`
+	Node       string       `json:"node"`
+	UPID       proxmox.UPID `json:"upid" table:"UPID"`
+	Status     string       `json:"status"`
+	ExitStatus string       `json:"exitstatus" table:"Exit Status"`
+}
+
+func restore(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	volid := c.Args().First()
+	guestType, err := util.ArchiveGuestType(volid)
+	if err != nil {
+		return err
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	node := c.String("node")
+	if node == "" {
+		node = util.GetDefaultNode(c)
+	}
+	if node == "" {
+		node, err = util.FindArchiveNode(c.Context, client, volid)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := util.RestoreOptions{
+		VMID:    c.Uint64("vmid"),
+		Storage: c.String("storage"),
+		Unique:  c.Bool("unique"),
+		Start:   c.Bool("start"),
+	}
+	var ts []*proxmox.Task // the destroy task, whose log --log-file saves
+	if opts.VMID == 0 {
+		cluster, err := client.Cluster(c.Context)
+		if err != nil {
+			return util.WrapApiError(err)
+		}
+		nextId, err := cluster.NextID(c.Context)
+		if err != nil {
+			return util.WrapApiError(err)
+		}
+		opts.VMID = uint64(nextId)
+	} else { // check if a guest already exists with target VMID
+		destroyTask, err := util.OverwriteGuest(c, &client, opts.VMID)
+		if destroyTask != nil {
+			ts = append(ts, destroyTask)
+		}
+		if err != nil {
+			return errors.Join(err, util.SaveTaskLogs(c, ts...))
+		}
+	}
+
+	task, err := util.StartRestore(c.Context, &client, node, guestType, volid, opts)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Restore requested! (node: %s, vmid: %d, task: %s)\n", node, opts.VMID, task.UPID)
+
+	var waitErr error
+	if c.Bool("wait") {
+		waitErr = taskstatus.FollowCliTask(c, task, ts)
+		if waitErr != nil && !errors.Is(waitErr, tasks.ErrTaskFailed) {
+			return waitErr
+		}
+	} else {
+		if err := util.SaveTaskLogs(c, ts...); err != nil {
+			return err
+		}
+		if err := task.Ping(c.Context); err != nil {
+			return util.WrapApiError(err)
+		}
+		logrus.Info(tasks.GetWaitCmd(*task))
+	}
+	err = output.Print(c, restoreResult{
+		VMID:       opts.VMID,
+		Type:       guestType,
+		Node:       node,
+		UPID:       task.UPID,
+		Status:     task.Status,
+		ExitStatus: task.ExitStatus,
+	})
+	if err != nil {
+		return err
+	}
+	return waitErr
+}
@@ -0,0 +1,24 @@
+package resume
+
+import (
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/util"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "resume",
+	Usage:       "Resume a suspended or hibernated virtual machine",
+	UsageText:   "gomox resume [options] <GUEST>...",
+	Description: util.GuestsArgUsage,
+	Action:      resumeVm,
+	Flags:       util.StateFlags(),
+}
+
+func resumeVm(c *cli.Context) error {
+	task, err := util.RequestStateCli(c, util.StateRequestParams{RequestedState: util.ResumedState})
+	if err != nil || task == nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
@@ -1,14 +1,28 @@
 package cmd
 
 import (
+	"github.com/perchnet/gomox/cmd/backup"
+	"github.com/perchnet/gomox/cmd/backups"
 	"github.com/perchnet/gomox/cmd/clone"
 	"github.com/perchnet/gomox/cmd/config"
 	"github.com/perchnet/gomox/cmd/destroy"
 	"github.com/perchnet/gomox/cmd/list"
+	"github.com/perchnet/gomox/cmd/login"
+	"github.com/perchnet/gomox/cmd/logout"
+	"github.com/perchnet/gomox/cmd/profile"
 	"github.com/perchnet/gomox/cmd/pveVersion"
+	"github.com/perchnet/gomox/cmd/reboot"
+	"github.com/perchnet/gomox/cmd/reset"
+	"github.com/perchnet/gomox/cmd/restore"
+	"github.com/perchnet/gomox/cmd/resume"
 	"github.com/perchnet/gomox/cmd/set"
+	"github.com/perchnet/gomox/cmd/shutdown"
+	"github.com/perchnet/gomox/cmd/snapshot"
 	"github.com/perchnet/gomox/cmd/start"
 	"github.com/perchnet/gomox/cmd/stop"
+	"github.com/perchnet/gomox/cmd/storage"
+	"github.com/perchnet/gomox/cmd/suspend"
+	"github.com/perchnet/gomox/cmd/tasks"
 	"github.com/perchnet/gomox/cmd/taskstatus"
 	"github.com/urfave/cli/v2"
 )
@@ -17,12 +31,26 @@ func Commands() cli.Commands {
 	return cli.Commands{
 		start.Command,
 		stop.Command,
+		shutdown.Command,
+		reboot.Command,
+		reset.Command,
+		suspend.Command,
+		resume.Command,
 		pveVersion.Command,
 		clone.Command,
 		destroy.Command,
+		backup.Command,
+		backups.Command,
+		restore.Command,
 		taskstatus.Command,
+		tasks.Command,
 		list.Command,
 		config.Command,
 		set.Command,
+		snapshot.Command,
+		storage.Command,
+		profile.Command,
+		login.Command,
+		logout.Command,
 	}
 }
@@ -1,7 +1,6 @@
 package set
 
 import (
-	"strconv"
 	"strings"
 
 	"github.com/perchnet/gomox/cmd/taskstatus"
@@ -11,27 +10,20 @@ import (
 )
 
 var Command = &cli.Command{
-	Name:   "set",
-	Usage:  "Set virtual machine hardware",
-	Action: set,
-	Flags:  []cli.Flag{},
+	Name:        "set",
+	Usage:       "Set virtual machine hardware",
+	UsageText:   "gomox set <GUEST> --OPTION VALUE...",
+	Description: util.GuestArgUsage,
+	Action:      set,
+	Flags:       []cli.Flag{},
 }
 
 func set(c *cli.Context) error {
-	client := util.InstantiateClient(
-		util.GetPveUrl(c),
-		proxmox.Credentials{
-			Username: c.String("pveuser"),
-			Password: c.String("pvepassword"),
-			Realm:    c.String("pverealm"),
-		},
-	)
-	vmid, err := strconv.Atoi(c.Args().First())
+	client, err := util.GetClient(c)
 	if err != nil {
 		return err
 	}
-
-	vm, err := util.GetVirtualMachineByVMID(c.Context, uint64(vmid), client)
+	vm, err := util.GetVirtualMachineArg(c.Context, client, c.Args().Slice())
 	if err != nil {
 		return err
 	}
@@ -52,12 +44,8 @@ func set(c *cli.Context) error {
 	}
 	task, err := vm.Config(c.Context, options...)
 	if err != nil {
-		return err
-	}
-	err = taskstatus.WaitForCliTask(c, task)
-	if err != nil {
-		return err
+		return util.WrapApiError(err)
 	}
 
-	return nil
+	return taskstatus.FinishCliTask(c, task)
 }
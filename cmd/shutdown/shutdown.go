@@ -0,0 +1,41 @@
+package shutdown
+
+import (
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/util"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "shutdown",
+	Usage:       "Shut down a virtual machine cleanly, through the guest OS (ACPI)",
+	UsageText:   "gomox shutdown [options] <GUEST>...",
+	Description: util.GuestsArgUsage,
+	Action:      shutdownVm,
+	Flags: append(
+		util.StateFlags(),
+		&cli.Uint64Flag{
+			Name:        "timeout",
+			Usage:       "Wait at most `SECONDS` for the guest OS to shut down",
+			DefaultText: "the server's default",
+		},
+		&cli.BoolFlag{
+			Name:  "force-stop",
+			Usage: "Stop (power off) the VM if it hasn't shut down by the timeout",
+		},
+	),
+}
+
+func shutdownVm(c *cli.Context) error {
+	task, err := util.RequestStateCli(
+		c, util.StateRequestParams{
+			RequestedState: util.ShutdownState,
+			Timeout:        c.Uint64("timeout"),
+			ForceStop:      c.Bool("force-stop"),
+		},
+	)
+	if err != nil || task == nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
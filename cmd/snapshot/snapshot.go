@@ -0,0 +1,368 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/output"
+	"github.com/perchnet/gomox/tasks"
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:    "snapshot",
+	Aliases: []string{"snap"},
+	Usage:   "Manage snapshots of virtual machines and containers",
+	Subcommands: []*cli.Command{
+		{
+			Name:        "create",
+			Usage:       "Take a snapshot",
+			UsageText:   "gomox snapshot create [options] <GUEST> <NAME>",
+			Description: util.GuestArgUsage,
+			Action:      createSnapshot,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "vmstate",
+					Usage: "also save the RAM of a running VM (QEMU only)",
+				},
+				&cli.StringFlag{
+					Name:    "description",
+					Aliases: []string{"d"},
+					Usage:   "describe the snapshot with `TEXT`",
+				},
+			},
+		},
+		{
+			Name:        "list",
+			Aliases:     []string{"ls"},
+			Usage:       "List snapshots, as a tree of parents and children in tables",
+			UsageText:   "gomox snapshot list <GUEST>",
+			Description: util.GuestArgUsage,
+			Action:      listSnapshots,
+		},
+		{
+			Name:        "rollback",
+			Usage:       "Roll back to a snapshot",
+			UsageText:   "gomox snapshot rollback <GUEST> <NAME>",
+			Description: util.GuestArgUsage,
+			Action:      rollbackSnapshot,
+		},
+		{
+			Name:        "delete",
+			Aliases:     []string{"rm"},
+			Usage:       "Delete a snapshot",
+			UsageText:   "gomox snapshot delete [--force] <GUEST> <NAME>",
+			Description: util.GuestArgUsage,
+			Action:      deleteSnapshot,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "remove the snapshot from the config even if deleting its disk snapshots fails",
+				},
+			},
+		},
+		pruneCommand,
+		{
+			Name:        "show",
+			Usage:       "Show the guest configuration saved in a snapshot",
+			UsageText:   "gomox snapshot show <GUEST> <NAME>",
+			Description: util.GuestArgUsage,
+			Action:      showSnapshot,
+		},
+	},
+}
+
+var pruneCommand = &cli.Command{
+	Name:  "prune",
+	Usage: "Delete the snapshots that a retention policy doesn't keep",
+	Description: "Snapshots are deleted one at a time per guest, and the guests in parallel. " +
+		"At least one --keep-* rule is required.\n\n" + util.GuestsArgUsage,
+	UsageText: "gomox snapshot prune [options] <GUEST>...",
+	Action:    pruneSnapshots,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only list what would be deleted",
+		},
+		util.ParallelFlag(),
+	},
+}
+
+func init() {
+	pruneCommand.Flags = append(pruneCommand.Flags, util.PruneFlags()...)
+}
+
+// The actions in the prune output.
+const (
+	pruneKeep    = "keep"
+	pruneDelete  = "delete" // with --dry-run
+	pruneDeleted = "deleted"
+	pruneFailed  = "failed"
+)
+
+// pruneRow is a row of the prune output.
+type pruneRow struct {
+	VMID     uint64    `json:"vmid" table:"VMID"`
+	Guest    string    `json:"guest"`
+	Snapshot string    `json:"snapshot"`
+	SnapTime time.Time `json:"snaptime" table:"Taken"`
+	Action   string    `json:"action"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// nowSnapshot marks the guest's current state in snapshot trees.
+var nowSnapshot = &util.Snapshot{Name: "NOW", Description: "You are here!"}
+
+// treeRow is a row of the snapshot tree.
+type treeRow struct {
+	Name        string    `json:"name"`
+	SnapTime    time.Time `json:"snaptime" table:"Taken"`
+	VMState     bool      `json:"vmstate" table:"RAM"`
+	Description string    `json:"description"`
+}
+
+// getArgs resolves the `<GUEST> <NAME>` arguments.
+func getArgs(c *cli.Context, client proxmox.Client) (*proxmox.ClusterResource, string, error) {
+	if c.Args().Len() != 2 {
+		return nil, "", fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	rs, err := util.ResolveGuest(c.Context, client, c.Args().Get(0))
+	if err != nil {
+		return nil, "", err
+	}
+	return rs, c.Args().Get(1), nil
+}
+
+func createSnapshot(c *cli.Context) error {
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	rs, name, err := getArgs(c, client)
+	if err != nil {
+		return err
+	}
+	task, err := util.CreateSnapshot(c.Context, &client, rs, name, c.String("description"), c.Bool("vmstate"))
+	if err != nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
+
+func rollbackSnapshot(c *cli.Context) error {
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	rs, name, err := getArgs(c, client)
+	if err != nil {
+		return err
+	}
+	task, err := util.RollbackSnapshot(c.Context, &client, rs, name)
+	if err != nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
+
+func deleteSnapshot(c *cli.Context) error {
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	rs, name, err := getArgs(c, client)
+	if err != nil {
+		return err
+	}
+	task, err := util.DeleteSnapshot(c.Context, &client, rs, name, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
+
+func showSnapshot(c *cli.Context) error {
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	rs, name, err := getArgs(c, client)
+	if err != nil {
+		return err
+	}
+	config, err := util.GetSnapshotConfig(c.Context, client, rs, name)
+	if err != nil {
+		return err
+	}
+	return output.Print(c, config)
+}
+
+func listSnapshots(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	rs, err := util.ResolveGuest(c.Context, client, c.Args().First())
+	if err != nil {
+		return err
+	}
+	snapshots, err := util.ListSnapshots(c.Context, client, rs)
+	if err != nil {
+		return err
+	}
+	if !output.IsTable(c) {
+		return output.Print(c, snapshots)
+	}
+	return output.Print(c, treeRows(snapshots))
+}
+
+func pruneSnapshots(c *cli.Context) error {
+	policy, err := util.GetPrunePolicy(c)
+	if err != nil {
+		return err
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	guests, err := util.ResolveGuestArgs(c.Context, client, c.Args().Slice(), false)
+	if err != nil {
+		return err
+	}
+
+	results := make([][]pruneRow, len(guests))
+	errs := make([][]error, len(guests))
+	_ = util.RunParallel(c.Context, len(guests), c.Int("parallel"), func(ctx context.Context, i int) error {
+		rs := guests[i]
+		row := func(s *util.Snapshot, action string, err error) {
+			r := pruneRow{VMID: rs.VMID, Guest: rs.Name, Action: action}
+			if s != nil {
+				r.Snapshot, r.SnapTime = s.Name, s.SnapTime
+			}
+			if err != nil {
+				r.Message = err.Error()
+				errs[i] = append(errs[i], err)
+			}
+			results[i] = append(results[i], r)
+		}
+
+		snapshots, err := util.ListSnapshots(ctx, client, rs)
+		if err != nil {
+			row(nil, pruneFailed, err)
+			return nil
+		}
+		keep, prune := policy.Select(snapshots)
+		for _, s := range keep {
+			row(s, pruneKeep, nil)
+		}
+		for _, s := range prune {
+			if c.Bool("dry-run") {
+				row(s, pruneDelete, nil)
+				continue
+			}
+			logrus.Infof("Deleting snapshot %s of guest %d\n", s.Name, rs.VMID)
+			if err := deleteAndWait(ctx, c, &client, rs, s.Name); err != nil {
+				row(s, pruneFailed, err)
+				continue
+			}
+			row(s, pruneDeleted, nil)
+		}
+		sort.SliceStable(results[i], func(a, b int) bool {
+			return results[i][a].SnapTime.After(results[i][b].SnapTime)
+		})
+		return nil
+	})
+
+	rows := []pruneRow{}
+	var allErrs []error
+	for i := range guests {
+		rows = append(rows, results[i]...)
+		allErrs = append(allErrs, errs[i]...)
+	}
+	if err := output.Print(c, rows); err != nil {
+		return err
+	}
+	return errors.Join(allErrs...)
+}
+
+// deleteAndWait deletes a snapshot and waits for the deletion to finish, as
+// a guest can only run one snapshot task at a time.
+func deleteAndWait(
+	ctx context.Context,
+	c *cli.Context,
+	client *proxmox.Client,
+	rs *proxmox.ClusterResource,
+	name string,
+) error {
+	task, err := util.DeleteSnapshot(ctx, client, rs, name, false)
+	if err != nil {
+		return err
+	}
+	if err := tasks.WaitTask(ctx, task, tasks.WithPolling(util.PollingOptions(c)...)); err != nil {
+		return err
+	}
+	if err := task.Ping(ctx); err != nil {
+		return util.WrapApiError(err)
+	}
+	return tasks.CheckFailed(task)
+}
+
+// treeRows lays snapshots out as a tree, each under its parent, with the
+// guest's current state as NOW, at the top level if it has no parent.
+func treeRows(snapshots []*util.Snapshot) []treeRow {
+	names := map[string]bool{}
+	for _, s := range snapshots {
+		names[s.Name] = true
+	}
+	var roots []*util.Snapshot
+	children := map[string][]*util.Snapshot{}
+	hasCurrent := false
+	for _, s := range snapshots {
+		if s.Parent == "" || !names[s.Parent] {
+			roots = append(roots, s)
+		} else {
+			children[s.Parent] = append(children[s.Parent], s)
+		}
+		hasCurrent = hasCurrent || s.Current
+	}
+	if !hasCurrent { // the current state derives from no snapshot
+		roots = append(roots, nowSnapshot)
+	}
+
+	rows := []treeRow{}
+	var walk func(list []*util.Snapshot, indent string, top bool)
+	walk = func(list []*util.Snapshot, indent string, top bool) {
+		for i, s := range list {
+			branch, next := "├─ ", "│  "
+			switch {
+			case top:
+				branch, next = "", ""
+			case i == len(list)-1:
+				branch, next = "└─ ", "   "
+			}
+			rows = append(rows, treeRow{
+				Name:        indent + branch + s.Name,
+				SnapTime:    s.SnapTime,
+				VMState:     s.VMState,
+				Description: s.Description,
+			})
+			kids := children[s.Name]
+			if s.Current {
+				kids = append(kids, nowSnapshot)
+			}
+			walk(kids, indent+next, false)
+		}
+	}
+	walk(roots, "", true)
+	return rows
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/perchnet/gomox/output"
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:  "storage",
+	Usage: "Browse storages and their content",
+	Subcommands: []*cli.Command{
+		listCommand,
+		contentCommand,
+	},
+}
+
+var listCommand = &cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List storages with their capacity",
+	Description: "Storages that aren't shared are listed once per node, shared ones once, without a node. " +
+		"Storages that aren't available, e.g. on offline nodes, are listed with a warning.",
+	UsageText: "gomox storage list [options]",
+	Action:    listStorages,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "node",
+			Usage: "only list the storages of `NODE` (repeatable)",
+		},
+		&cli.Float64Flag{
+			Name:  "threshold",
+			Usage: "warn about storages that are more than `PERCENT` full",
+		},
+	},
+}
+
+// storageRow is a row of the storage list output.
+type storageRow struct {
+	Storage string         `json:"storage"`
+	Node    string         `json:"node,omitempty"`
+	Type    string         `json:"type"`
+	Content string         `json:"content"`
+	Shared  bool           `json:"shared"`
+	Used    output.Bytes   `json:"used"`
+	Total   output.Bytes   `json:"total"`
+	Percent output.Percent `json:"used_percent" table:"Used%"`
+	Status  string         `json:"status"` // as in cluster/resources, e.g. available
+}
+
+// storageAvailable is the cluster/resources status of a storage in use.
+const storageAvailable = "available"
+
+var contentCommand = &cli.Command{
+	Name:  "content",
+	Usage: "List the volumes on a storage",
+	Description: "A storage that isn't shared is listed on every node that has it, " +
+		"unless --node or the profile picks one.",
+	UsageText: "gomox storage content [options] <STORAGE>",
+	Action:    listContent,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "node",
+			Usage:       "list the storage as seen from `NODE`",
+			DefaultText: "the profile's node, or every node",
+		},
+	},
+}
+
+func init() {
+	contentCommand.Flags = append(contentCommand.Flags, util.ContentFilterFlags()...)
+}
+
+func listStorages(c *cli.Context) error {
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	resources, err := util.GetResourceList(c.Context, client,
+		util.WithStorage(), util.WithFilter(&util.ResourceFilter{Nodes: c.StringSlice("node")}))
+	if err != nil {
+		return err
+	}
+
+	rows := []storageRow{}
+	sharedRows := map[string]int{} // index in rows
+	for _, rs := range resources {
+		row := storageRow{
+			Storage: rs.Storage,
+			Node:    rs.Node,
+			Type:    rs.PluginType,
+			Content: rs.Content,
+			Shared:  rs.Shared != 0,
+			Used:    output.Bytes(rs.Disk),
+			Total:   output.Bytes(rs.MaxDisk),
+			Status:  rs.Status,
+		}
+		if rs.MaxDisk > 0 {
+			row.Percent = output.Percent(float64(rs.Disk) * 100 / float64(rs.MaxDisk))
+		}
+		if row.Shared {
+			// listed once, as seen from a node where it is available
+			row.Node = ""
+			if i, ok := sharedRows[rs.Storage]; ok {
+				if rows[i].Status != storageAvailable {
+					rows[i] = row
+				}
+				continue
+			}
+			sharedRows[rs.Storage] = len(rows)
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Storage != rows[j].Storage {
+			return rows[i].Storage < rows[j].Storage
+		}
+		return rows[i].Node < rows[j].Node
+	})
+
+	for _, row := range rows {
+		where := "shared"
+		if row.Node != "" {
+			where = "on node " + row.Node
+		}
+		if row.Status != storageAvailable {
+			logrus.Warnf("Storage %s (%s) is %s\n", row.Storage, where, row.Status)
+			continue
+		}
+		if threshold := c.Float64("threshold"); c.IsSet("threshold") && float64(row.Percent) > threshold {
+			logrus.Warnf("Storage %s (%s) is %s full, over the threshold of %g%%\n",
+				row.Storage, where, row.Percent, threshold)
+		}
+	}
+	return output.Print(c, rows)
+}
+
+func listContent(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	storage := c.Args().First()
+	filter, err := util.GetContentFilter(c)
+	if err != nil {
+		return err
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	node := c.String("node")
+	if node == "" {
+		node = util.GetDefaultNode(c)
+	}
+	var locations []util.StorageLocation
+	if node != "" {
+		locations = []util.StorageLocation{{Node: node, Storage: storage}}
+	} else {
+		locations, err = util.FindStorages(c.Context, client, storage, nil)
+		if err != nil {
+			return err
+		}
+		if len(locations) == 0 {
+			return fmt.Errorf("storage %s is not available on any node", storage)
+		}
+	}
+
+	volumes, listErr := util.ListContentAt(c.Context, client, locations, filter)
+	if err := output.Print(c, volumes); err != nil {
+		return err
+	}
+	return listErr
+}
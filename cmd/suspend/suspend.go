@@ -0,0 +1,34 @@
+package suspend
+
+import (
+	"github.com/perchnet/gomox/cmd/taskstatus"
+	"github.com/perchnet/gomox/util"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "suspend",
+	Usage:       "Suspend (pause) a virtual machine, or hibernate it with --to-disk",
+	UsageText:   "gomox suspend [options] <GUEST>...",
+	Description: util.GuestsArgUsage,
+	Action:      suspendVm,
+	Flags: append(
+		util.StateFlags(),
+		&cli.BoolFlag{
+			Name:  "to-disk",
+			Usage: "Hibernate: save the VM's memory to disk and stop it",
+		},
+	),
+}
+
+func suspendVm(c *cli.Context) error {
+	requestedState := util.PausedState
+	if c.Bool("to-disk") {
+		requestedState = util.HibernatedState
+	}
+	task, err := util.RequestStateCli(c, util.StateRequestParams{RequestedState: requestedState})
+	if err != nil || task == nil {
+		return err
+	}
+	return taskstatus.FinishCliTask(c, task)
+}
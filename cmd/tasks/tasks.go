@@ -0,0 +1,273 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/output"
+	tasklib "github.com/perchnet/gomox/tasks"
+	"github.com/perchnet/gomox/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:  "tasks",
+	Usage: "Browse the task history",
+	Subcommands: []*cli.Command{
+		listCommand,
+		logCommand,
+		stopCommand,
+	},
+}
+
+var listCommand = &cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List recent tasks, or search the task history",
+	Description: "Without --node, --since or --until, the cluster's recent and running tasks are listed;\n" +
+		"otherwise the task history of the nodes is searched.",
+	UsageText: "gomox tasks list [options]",
+	Action:    listTasks,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "list at most the `N` most recent tasks",
+			Value: util.DefaultTaskLimit,
+		},
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f", "live"},
+			Usage:   "keep listing tasks as they start and finish, until interrupted",
+		},
+		&cli.IntFlag{
+			Name:  "interval",
+			Usage: "with --follow, check for new tasks every `INTERVAL` seconds",
+			Value: 2,
+		},
+	},
+}
+
+var logCommand = &cli.Command{
+	Name:      "log",
+	Usage:     "Print the complete log of a task",
+	UsageText: "gomox tasks log [--follow] <UPID>",
+	Action:    taskLog,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f"},
+			Usage:   "keep printing the log as it is written, until the task ends",
+		},
+	},
+}
+
+var stopCommand = &cli.Command{
+	Name:      "stop",
+	Usage:     "Stop running tasks and wait for them to end",
+	UsageText: "gomox tasks stop [--all-for <VMID>] [<UPID>...]",
+	Action:    stopTasks,
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "all-for",
+			Usage: "stop every running task for guest `VMID`",
+		},
+	},
+}
+
+func init() {
+	listCommand.Flags = append(listCommand.Flags, util.TaskFilterFlags()...)
+}
+
+func listTasks(c *cli.Context) error {
+	filter, err := util.GetTaskFilter(c)
+	if err != nil {
+		return err
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	if c.Bool("follow") {
+		return followTasks(c, client, filter)
+	}
+
+	list, err := util.ListTasks(c.Context, client, filter, c.Int("limit"))
+	if err != nil {
+		return err
+	}
+	summaries := []tasklib.Summary{}
+	for _, task := range list {
+		summaries = append(summaries, tasklib.NewSummary(task))
+	}
+	return output.Print(c, summaries)
+}
+
+func taskLog(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	task := proxmox.NewTask(proxmox.UPID(c.Args().First()), &client)
+	if task == nil {
+		return fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+
+	if !c.Bool("follow") {
+		lines, err := tasklib.ReadLog(c.Context, task)
+		if err != nil {
+			return util.WrapApiError(err)
+		}
+		if !output.IsTable(c) {
+			return output.Print(c, lines)
+		}
+		for _, ln := range lines {
+			fmt.Fprintln(c.App.Writer, ln)
+		}
+		return nil
+	}
+
+	watch, err := tasklib.Watch(c.Context, 0, task)
+	if err != nil {
+		return util.WrapApiError(err)
+	}
+	for ln := range watch {
+		fmt.Fprintln(c.App.Writer, ln)
+	}
+	if c.Context.Err() != nil {
+		return nil
+	}
+	if err := task.Ping(c.Context); err != nil {
+		return util.WrapApiError(err)
+	}
+	return tasklib.CheckFailed(task)
+}
+
+func stopTasks(c *cli.Context) error {
+	if c.Args().Len() == 0 && !c.IsSet("all-for") {
+		return fmt.Errorf("Usage: %s", c.Command.UsageText)
+	}
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
+	var ts []*proxmox.Task
+	for _, upid := range c.Args().Slice() {
+		task := proxmox.NewTask(proxmox.UPID(upid), &client)
+		if task == nil {
+			return fmt.Errorf("Usage: %s", c.Command.UsageText)
+		}
+		ts = append(ts, task)
+	}
+	if vmid := c.Uint64("all-for"); vmid != 0 {
+		filter := &util.TaskFilter{VMID: vmid, State: util.TaskStateRunning}
+		list, err := util.ListTasks(c.Context, client, filter, 0)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 && len(ts) == 0 {
+			logrus.Infof("No running tasks for guest %d\n", vmid)
+			return nil
+		}
+		for _, task := range list {
+			ts = append(ts, proxmox.NewTask(task.UPID, &client))
+		}
+	}
+
+	// stop what is still running, then wait for it to end
+	var (
+		errs    []error
+		found   []*proxmox.Task
+		stopped []*proxmox.Task
+	)
+	for _, task := range ts {
+		if err := task.Ping(c.Context); err != nil {
+			errs = append(errs, util.WrapApiError(fmt.Errorf("task %s: %w", task.UPID, err)))
+			continue
+		}
+		found = append(found, task)
+		if !task.IsRunning {
+			logrus.Warnf("Task %s already ended (%s)\n", task.UPID, task.ExitStatus)
+			continue
+		}
+		if err := task.Stop(c.Context); err != nil {
+			errs = append(errs, util.WrapApiError(fmt.Errorf("stopping task %s: %w", task.UPID, err)))
+			continue
+		}
+		logrus.Infof("Stop requested! (task: %s)\n", task.UPID)
+		stopped = append(stopped, task)
+	}
+	waitOpts := []tasklib.WaitOption{tasklib.WithPolling(util.PollingOptions(c)...)}
+	if !c.Bool("quiet") {
+		waitOpts = append(waitOpts, tasklib.WithOutput())
+	}
+	if err := tasklib.WaitAll(c.Context, stopped, waitOpts...); err != nil {
+		errs = append(errs, err)
+	}
+
+	summaries := []tasklib.Summary{}
+	for _, task := range found {
+		if err := task.Ping(c.Context); err != nil {
+			errs = append(errs, util.WrapApiError(err))
+			continue
+		}
+		summaries = append(summaries, tasklib.NewSummary(task))
+	}
+	if err := output.Print(c, summaries); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// followTasks prints the tasks that pass filter, and then every task that
+// starts or finishes, until the command is interrupted (`tasks list
+// --follow`).
+func followTasks(c *cli.Context, client proxmox.Client, filter *util.TaskFilter) error {
+	if c.Int("interval") < 1 {
+		return fmt.Errorf("--interval must be at least 1 second")
+	}
+	stream, err := output.NewStream(c)
+	if err != nil {
+		return err
+	}
+	states := map[proxmox.UPID]string{}
+	for {
+		list, err := util.ListTasks(c.Context, client, filter, c.Int("limit"))
+		if err != nil {
+			if c.Context.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var changed []tasklib.Summary
+		listed := map[proxmox.UPID]bool{}
+		for _, task := range list {
+			listed[task.UPID] = true
+			state := util.TaskState(task)
+			if states[task.UPID] != state {
+				states[task.UPID] = state
+				changed = append(changed, tasklib.NewSummary(task))
+			}
+		}
+		// forget the finished tasks that are no longer listed
+		for upid, state := range states {
+			if !listed[upid] && state != util.TaskStateRunning {
+				delete(states, upid)
+			}
+		}
+		if err := stream.Print(changed); err != nil {
+			return err
+		}
+
+		select {
+		case <-c.Context.Done():
+			return nil
+		case <-time.After(time.Duration(c.Int("interval")) * time.Second):
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package taskstatus
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/perchnet/gomox/output"
 	"github.com/perchnet/gomox/tasks"
 	"github.com/perchnet/gomox/util"
 	"github.com/luthermonson/go-proxmox"
@@ -10,18 +13,18 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-const UsageText = "gomox [-w] taskstatus <UPID>"
+const UsageText = "gomox [-w] taskstatus [options] <UPID>..."
 
 var Command = &cli.Command{
 	Name:      "taskstatus",
-	Usage:     "Get the status of a given task, by UPID",
+	Usage:     "Get the status of the given tasks, by UPID",
 	UsageText: UsageText,
 	Action:    taskStatusCmd,
 	Flags: []cli.Flag{
 		&cli.IntFlag{
 			Name:     "timeout",
 			Category: "wait",
-			Usage:    "Wait up to `TIMEOUT` seconds for task completion",
+			Usage:    "Wait up to `TIMEOUT` seconds for task completion (overrides --wait-timeout)",
 			// Value:    30,
 			Aliases: []string{"s"},
 		},
@@ -36,58 +39,139 @@ var Command = &cli.Command{
 }
 
 func taskStatusCmd(c *cli.Context) error {
-	client := util.InstantiateClient(
-		util.GetPveUrl(c),
-		proxmox.Credentials{
-			Username: c.String("pveuser"),
-			Password: c.String("pvepassword"),
-			Realm:    c.String("pverealm"),
-		},
-	)
+	client, err := util.GetClient(c)
+	if err != nil {
+		return err
+	}
 	if len(c.Args().Slice()) == 0 {
 		return fmt.Errorf("Usage: " + UsageText)
 	}
-	upid := c.Args().First()
-	tailMode := c.Bool("wait")
-	task := proxmox.NewTask(proxmox.UPID(upid), &client)
+	if c.Int("interval") < 1 {
+		return fmt.Errorf("--interval must be at least 1 second")
+	}
+	var ts []*proxmox.Task
+	for _, upid := range c.Args().Slice() {
+		task := proxmox.NewTask(proxmox.UPID(upid), &client)
+		if task == nil {
+			return fmt.Errorf("Usage: " + UsageText)
+		}
+		taskStatus, err := tasks.TaskStatus(c.Context, task)
+		if err != nil && !errors.Is(err, tasks.ErrTaskFailed) {
+			return util.WrapApiError(err)
+		}
+		logrus.Debug(taskStatus)
+		ts = append(ts, task)
+	}
 
-	taskStatus, err := tasks.TaskStatus(c.Context, *task)
+	opts := []tasks.PollingOption{
+		tasks.WithPollDuration(time.Duration(c.Int("interval")) * time.Second),
+	}
+	if c.IsSet("timeout") {
+		timeout := time.Duration(c.Int("timeout")) * time.Second
+		opts = append(opts, tasks.WithTimeout(timeout, c.Bool("stop-on-timeout")))
+	}
+	if len(ts) > 1 {
+		return FinishCliTasks(c, ts, opts...)
+	}
+
+	task := ts[0]
+	if task.IsRunning && c.Bool("wait") {
+		return FinishCliTask(c, task, opts...)
+	}
+	err = output.Print(c, tasks.NewSummary(task))
 	if err != nil {
 		return err
 	}
-	logrus.Info(taskStatus)
-	if task.IsRunning && tailMode {
-		err = WaitForCliTask(c, task)
-		if err != nil {
+
+	return tasks.CheckFailed(task)
+}
+
+// WaitForCliTask waits for `task` to complete, and returns a
+// tasks.TaskFailedError if it failed, or a tasks.TaskTimeoutError if it
+// didn't complete within `--wait-timeout`. opts override the flags.
+func WaitForCliTask(c *cli.Context, task *proxmox.Task, opts ...tasks.PollingOption) error {
+	return waitForCliTask(c, task, nil, []tasks.WaitOption{tasks.WithOutput(), tasks.WithSpinner()}, opts)
+}
+
+// FollowCliTask is WaitForCliTask for tasks whose log is their progress,
+// like restores: unless `--quiet`, every line of the log is printed as it is
+// written. The logs of the finished tasks in before, which led up to task,
+// are saved to `--log-file` ahead of its own.
+func FollowCliTask(c *cli.Context, task *proxmox.Task, before []*proxmox.Task, opts ...tasks.PollingOption) error {
+	return waitForCliTask(c, task, before, []tasks.WaitOption{tasks.WithLogLines()}, opts)
+}
+
+func waitForCliTask(
+	c *cli.Context,
+	task *proxmox.Task,
+	before []*proxmox.Task,
+	output []tasks.WaitOption,
+	opts []tasks.PollingOption,
+) error {
+	waitOpts := []tasks.WaitOption{tasks.WithPolling(append(util.PollingOptions(c), opts...)...)}
+	if !c.Bool("quiet") {
+		waitOpts = append(waitOpts, output...)
+	}
+	err := tasks.WaitTask(c.Context, task, waitOpts...)
+	// save the logs even if waiting failed, they may tell why
+	if err := errors.Join(err, util.SaveTaskLogs(c, append(before[:len(before):len(before)], task)...)); err != nil {
+		return err
+	}
+	if err := task.Ping(c.Context); err != nil {
+		return util.WrapApiError(err)
+	}
+	return tasks.CheckFailed(task)
+}
+
+// FinishCliTask is how commands end once they have started a task: it waits
+// for the task with WaitForCliTask if `--wait` is set, prints its summary, and
+// returns a tasks.TaskFailedError if it failed. opts are passed to
+// WaitForCliTask.
+func FinishCliTask(c *cli.Context, task *proxmox.Task, opts ...tasks.PollingOption) error {
+	if c.Bool("wait") {
+		err := WaitForCliTask(c, task, opts...)
+		if err != nil && !errors.Is(err, tasks.ErrTaskFailed) {
 			return err
 		}
+	} else if err := task.Ping(c.Context); err != nil {
+		return util.WrapApiError(err)
 	}
-
-	return nil
+	if err := output.Print(c, tasks.NewSummary(task)); err != nil {
+		return err
+	}
+	return tasks.CheckFailed(task)
 }
 
-// WaitForCliTask waits for `task` to complete
-func WaitForCliTask(c *cli.Context, task *proxmox.Task) error {
-	var err error
-	if c.Bool("quiet") {
-		err = tasks.WaitTask(
-			c.Context,
-			task,
-		)
-		if err != nil {
-			return err
+// FinishCliTasks is FinishCliTask for several tasks: with `--wait`, it waits
+// for them together with tasks.WaitAll, showing their combined progress. The
+// summaries are printed even if waiting failed, and the errors are joined.
+func FinishCliTasks(c *cli.Context, ts []*proxmox.Task, opts ...tasks.PollingOption) error {
+	var errs []error
+	if c.Bool("wait") {
+		waitOpts := []tasks.WaitOption{
+			tasks.WithPolling(append(util.PollingOptions(c), opts...)...),
 		}
-	} else {
-		err = tasks.WaitTask(
-			c.Context,
-			task,
-			tasks.WithOutput(),
-			tasks.WithPolling(),
-			tasks.WithSpinner(),
-		)
-		if err != nil {
-			return err
+		if !c.Bool("quiet") {
+			waitOpts = append(waitOpts, tasks.WithOutput())
+		}
+		if err := tasks.WaitAll(c.Context, ts, waitOpts...); err != nil {
+			errs = append(errs, err)
 		}
+		if err := util.SaveTaskLogs(c, ts...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	summaries := make([]tasks.Summary, len(ts))
+	for i, task := range ts {
+		if err := task.Ping(c.Context); err != nil {
+			return errors.Join(append(errs, util.WrapApiError(err))...)
+		}
+		summaries[i] = tasks.NewSummary(task)
+		errs = append(errs, tasks.CheckFailed(task))
+	}
+	if err := output.Print(c, summaries); err != nil {
+		return err
 	}
-	return nil
+	return errors.Join(errs...)
 }
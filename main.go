@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/perchnet/gomox/cmd"
+	"github.com/perchnet/gomox/output"
+	"github.com/perchnet/gomox/util"
 	"github.com/sirupsen/logrus"
 	easy "github.com/t-tomalak/logrus-easy-formatter"
 	"github.com/urfave/cli/v2"
@@ -12,10 +18,25 @@ import (
 
 func main() {
 	app := &cli.App{
-		Name:     "gomox",
-		Usage:    "gomox",
+		Name:  "gomox",
+		Usage: "gomox",
+		Description: "Exit codes: 0 success, 1 usage or other error, 2 task failed, " +
+			"3 timed out waiting for a task, 4 Proxmox API error, 130 interrupted.",
 		Commands: cmd.Commands(),
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "profile",
+				Aliases: []string{"P"},
+				Usage:   "Use the connection settings of profile `NAME` from the config file",
+				EnvVars: []string{"GOMOX_PROFILE"},
+			},
+			&cli.StringFlag{
+				Name:        "config",
+				Usage:       "Read profiles from `FILE`",
+				EnvVars:     []string{"GOMOX_CONFIG"},
+				DefaultText: util.DefaultConfigPath(),
+				TakesFile:   true,
+			},
 			&cli.StringFlag{
 				Name:    "pveuser",
 				Aliases: []string{"u"},
@@ -37,6 +58,24 @@ func main() {
 				Usage:   "Proxmox VE authentication realm",
 				EnvVars: []string{"PVE_REALM"},
 			},
+			&cli.StringFlag{
+				Name:    "otp",
+				Value:   "",
+				Usage:   "Two-factor `CODE` (TOTP, or e.g. recovery:CODE) for password logins",
+				EnvVars: []string{"PVE_OTP"},
+			},
+			&cli.StringFlag{
+				Name:    "token-id",
+				Value:   "",
+				Usage:   "Proxmox VE API token ID (`user@realm!tokenname`), used instead of a password",
+				EnvVars: []string{"PVE_TOKEN_ID"},
+			},
+			&cli.StringFlag{
+				Name:    "token-secret",
+				Value:   "",
+				Usage:   "Proxmox VE API token secret",
+				EnvVars: []string{"PVE_TOKEN_SECRET"},
+			},
 			&cli.StringFlag{
 				Name:    "pveurl",
 				Usage:   "Proxmox VE API URL",
@@ -62,6 +101,33 @@ func main() {
 				Usage:   "Proxmox VE API port",
 				EnvVars: []string{"PVE_PORT"},
 			},
+			&cli.BoolFlag{
+				Name:     "insecure",
+				Aliases:  []string{"k"},
+				Usage:    "Skip verification of the API server's TLS certificate",
+				EnvVars:  []string{"PVE_INSECURE"},
+				Category: "tls",
+			},
+			&cli.StringFlag{
+				Name:      "ca-file",
+				Usage:     "Trust the CA certificates in the PEM bundle `FILE`",
+				EnvVars:   []string{"PVE_CA_FILE"},
+				TakesFile: true,
+				Category:  "tls",
+			},
+			&cli.StringFlag{
+				Name:     "fingerprint",
+				Usage:    "Pin the API server's certificate to its SHA-256 `FINGERPRINT` (AB:CD:...)",
+				EnvVars:  []string{"PVE_FINGERPRINT"},
+				Category: "tls",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Value:   output.TableFormat,
+				Usage:   "Output `FORMAT`: " + strings.Join(output.Formats, ", ") + ", go-template=TEMPLATE, go-template-file=FILE or jsonpath=EXPR",
+				EnvVars: []string{"GOMOX_OUTPUT"},
+			},
 			&cli.BoolFlag{
 				Name:    "debug",
 				Aliases: []string{"d"},
@@ -85,6 +151,23 @@ func main() {
 					// so I'll just sort of let you pick which keyword to use :)
 				},
 			},
+			&cli.IntFlag{
+				Name:     "wait-timeout",
+				Usage:    "Give up waiting for a task after `SECONDS` (0 waits forever)",
+				Category: "wait",
+				EnvVars:  []string{"GOMOX_WAIT_TIMEOUT"},
+			},
+			&cli.StringFlag{
+				Name:      "log-file",
+				Usage:     "Save the complete log of the tasks waited for to `FILE`",
+				Category:  "wait",
+				TakesFile: true,
+			},
+			&cli.BoolFlag{
+				Name:     "stop-on-timeout",
+				Usage:    "Stop the task when --wait-timeout runs out",
+				Category: "wait",
+			},
 		},
 		Before: func(ctx *cli.Context) error {
 			if ctx.Bool("debug") {
@@ -100,13 +183,20 @@ func main() {
 			if ctx.Bool("quiet") {
 				logrus.SetOutput(io.Discard)
 			}
+			if err := output.CheckFormat(ctx.String("output")); err != nil {
+				return err
+			}
 
-			return nil
+			return util.ApplyProfile(ctx)
 		},
 	}
 
-	err := app.Run(os.Args)
+	// cancel running requests and waits on ^C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := app.RunContext(ctx, os.Args)
+	stop()
 	if err != nil {
-		logrus.Fatal(err)
+		logrus.Errorf("%s\n", err)
+		os.Exit(util.ExitCode(err))
 	}
 }
@@ -0,0 +1,646 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+JsonPath is a parsed kubectl-style JSONPath template such as
+
+	{range [*]}{.vmid}{"\t"}{.name}{"\n"}{end}
+
+Text outside braces is printed as-is. Inside braces are paths (`.name`,
+`['name']`, `[0]`, `[-1]`, `[1:3]`, `[*]`, `..name`, and filters like
+`[?(@.status=="running")]`), quoted string literals, and `range`/`end`
+blocks. Paths start at the current `range` element (`@`) or the root (`$`);
+missing keys print nothing. When a path matches several values they are
+separated by spaces.
+*/
+type JsonPath struct {
+	nodes []jpNode
+}
+
+type jpNode interface{}
+
+type jpText string
+
+type jpPath struct {
+	root  bool // starts at `$` rather than the current element
+	steps []jpStep
+}
+
+type jpRange struct {
+	path jpPath
+	body []jpNode
+}
+
+type jpStep struct {
+	kind      jpStepKind
+	name      string
+	index     int
+	start     *int
+	end       *int
+	condition *jpCondition
+}
+
+type jpStepKind int
+
+const (
+	jpField jpStepKind = iota
+	jpRecursive
+	jpWildcard
+	jpIndex
+	jpSlice
+	jpFilter
+)
+
+type jpCondition struct {
+	left  jpOperand
+	op    string // empty: left must exist and be truthy
+	right jpOperand
+}
+
+type jpOperand struct {
+	path    *jpPath
+	literal interface{}
+}
+
+// ParseJsonPath parses a JSONPath template. A template without braces is
+// treated as a single path, so `jsonpath=.name` works like `jsonpath={.name}`.
+func ParseJsonPath(text string) (*JsonPath, error) {
+	if !strings.Contains(text, "{") {
+		text = "{" + text + "}"
+	}
+	nodes, rest, err := parseJpNodes(text, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonpath %q: %w", text, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid jsonpath %q: unexpected {end}", text)
+	}
+	return &JsonPath{nodes: nodes}, nil
+}
+
+// parseJpNodes parses text up to the end of input or, inside a range, up to
+// its {end}, and returns what is left after it.
+func parseJpNodes(text string, inRange bool) ([]jpNode, string, error) {
+	var nodes []jpNode
+	for text != "" {
+		open := strings.IndexByte(text, '{')
+		if open < 0 {
+			nodes = append(nodes, jpText(text))
+			text = ""
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, jpText(text[:open]))
+		}
+		close, err := matchingBrace(text, open)
+		if err != nil {
+			return nil, "", err
+		}
+		expr := strings.TrimSpace(text[open+1 : close])
+		text = text[close+1:]
+
+		switch {
+		case expr == "end":
+			if !inRange {
+				return nil, "end", nil
+			}
+			return nodes, text, nil
+		case strings.HasPrefix(expr, "range ") || expr == "range":
+			path, err := parseJpPath(strings.TrimSpace(strings.TrimPrefix(expr, "range")))
+			if err != nil {
+				return nil, "", err
+			}
+			body, rest, err := parseJpNodes(text, true)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, jpRange{path: path, body: body})
+			text = rest
+		case strings.HasPrefix(expr, `"`) || strings.HasPrefix(expr, "'"):
+			s, err := unquote(expr)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, jpText(s))
+		default:
+			path, err := parseJpPath(expr)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, path)
+		}
+	}
+	if inRange {
+		return nil, "", fmt.Errorf("{range} without {end}")
+	}
+	return nodes, "", nil
+}
+
+// matchingBrace returns the index of the brace closing the one at open,
+// skipping over quoted strings.
+func matchingBrace(text string, open int) (int, error) {
+	var quote byte
+	for i := open + 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unclosed {")
+}
+
+func parseJpPath(expr string) (jpPath, error) {
+	var path jpPath
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		path.root = true
+		expr = expr[1:]
+	case strings.HasPrefix(expr, "@"):
+		expr = expr[1:]
+	}
+	for expr != "" {
+		switch {
+		case strings.HasPrefix(expr, ".."):
+			name, rest := splitName(expr[2:])
+			if name == "" {
+				return path, fmt.Errorf("expected a name after ..")
+			}
+			path.steps = append(path.steps, jpStep{kind: jpRecursive, name: name})
+			expr = rest
+		case strings.HasPrefix(expr, "."):
+			name, rest := splitName(expr[1:])
+			if name == "*" {
+				path.steps = append(path.steps, jpStep{kind: jpWildcard})
+			} else if name != "" {
+				path.steps = append(path.steps, jpStep{kind: jpField, name: name})
+			}
+			expr = rest
+		case strings.HasPrefix(expr, "["):
+			end, err := matchingBracket(expr)
+			if err != nil {
+				return path, err
+			}
+			step, err := parseJpSubscript(strings.TrimSpace(expr[1:end]))
+			if err != nil {
+				return path, err
+			}
+			path.steps = append(path.steps, step)
+			expr = expr[end+1:]
+		default:
+			name, rest := splitName(expr)
+			if name == "" {
+				return path, fmt.Errorf("unexpected %q", expr)
+			}
+			path.steps = append(path.steps, jpStep{kind: jpField, name: name})
+			expr = rest
+		}
+	}
+	return path, nil
+}
+
+// splitName splits a leading field name off expr.
+func splitName(expr string) (string, string) {
+	if strings.HasPrefix(expr, "*") {
+		return "*", expr[1:]
+	}
+	i := strings.IndexAny(expr, ".[")
+	if i < 0 {
+		return expr, ""
+	}
+	return expr[:i], expr[i:]
+}
+
+func matchingBracket(expr string) (int, error) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unclosed [")
+}
+
+func parseJpSubscript(sub string) (jpStep, error) {
+	switch {
+	case sub == "*":
+		return jpStep{kind: jpWildcard}, nil
+	case strings.HasPrefix(sub, "?(") && strings.HasSuffix(sub, ")"):
+		cond, err := parseJpCondition(strings.TrimSpace(sub[2 : len(sub)-1]))
+		if err != nil {
+			return jpStep{}, err
+		}
+		return jpStep{kind: jpFilter, condition: cond}, nil
+	case strings.HasPrefix(sub, "'") || strings.HasPrefix(sub, `"`):
+		name, err := unquote(sub)
+		if err != nil {
+			return jpStep{}, err
+		}
+		return jpStep{kind: jpField, name: name}, nil
+	case strings.Contains(sub, ":"):
+		startText, endText, _ := strings.Cut(sub, ":")
+		step := jpStep{kind: jpSlice}
+		for _, bound := range []struct {
+			text string
+			dst  **int
+		}{{startText, &step.start}, {endText, &step.end}} {
+			if text := strings.TrimSpace(bound.text); text != "" {
+				n, err := strconv.Atoi(text)
+				if err != nil {
+					return jpStep{}, fmt.Errorf("bad slice [%s]", sub)
+				}
+				*bound.dst = &n
+			}
+		}
+		return step, nil
+	default:
+		n, err := strconv.Atoi(sub)
+		if err != nil {
+			return jpStep{}, fmt.Errorf("bad subscript [%s]", sub)
+		}
+		return jpStep{kind: jpIndex, index: n}, nil
+	}
+}
+
+var jpOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseJpCondition(expr string) (*jpCondition, error) {
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+			continue
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '"' || c == '\'':
+			quote = c
+			continue
+		}
+		for _, op := range jpOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				left, err := parseJpOperand(strings.TrimSpace(expr[:i]))
+				if err != nil {
+					return nil, err
+				}
+				right, err := parseJpOperand(strings.TrimSpace(expr[i+len(op):]))
+				if err != nil {
+					return nil, err
+				}
+				return &jpCondition{left: left, op: op, right: right}, nil
+			}
+		}
+	}
+	left, err := parseJpOperand(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &jpCondition{left: left}, nil
+}
+
+func parseJpOperand(expr string) (jpOperand, error) {
+	switch {
+	case strings.HasPrefix(expr, "@") || strings.HasPrefix(expr, "$"):
+		path, err := parseJpPath(expr)
+		return jpOperand{path: &path}, err
+	case strings.HasPrefix(expr, "'") || strings.HasPrefix(expr, `"`):
+		s, err := unquote(expr)
+		return jpOperand{literal: s}, err
+	case expr == "true" || expr == "false":
+		return jpOperand{literal: expr == "true"}, nil
+	case expr == "null":
+		return jpOperand{}, nil
+	}
+	n, err := strconv.ParseFloat(expr, 64)
+	if err != nil {
+		return jpOperand{}, fmt.Errorf("bad filter operand %q", expr)
+	}
+	return jpOperand{literal: n}, nil
+}
+
+// unquote accepts both 'single' and "double" quoted strings.
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") && len(s) >= 2 {
+		s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`), `\'`, `'`) + `"`
+	}
+	out, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("bad string literal %s", s)
+	}
+	return out, nil
+}
+
+// toJsonData converts v to the generic form JSONPath works on, with the same
+// field names as `--output json`.
+func toJsonData(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Execute evaluates the template against data, which must be in the generic
+// form produced by decoding JSON.
+func (jp *JsonPath) Execute(data interface{}) (string, error) {
+	var b strings.Builder
+	if err := executeJpNodes(&b, jp.nodes, data, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func executeJpNodes(b *strings.Builder, nodes []jpNode, root, current interface{}) error {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case jpText:
+			b.WriteString(string(n))
+		case jpPath:
+			values := n.eval(root, current)
+			for i, v := range values {
+				if i > 0 {
+					b.WriteByte(' ')
+				}
+				s, err := jpString(v)
+				if err != nil {
+					return err
+				}
+				b.WriteString(s)
+			}
+		case jpRange:
+			for _, v := range n.path.eval(root, current) {
+				items := []interface{}{v}
+				if list, ok := v.([]interface{}); ok {
+					items = list
+				}
+				for _, item := range items {
+					if err := executeJpNodes(b, n.body, root, item); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p jpPath) eval(root, current interface{}) []interface{} {
+	values := []interface{}{current}
+	if p.root {
+		values = []interface{}{root}
+	}
+	for _, step := range p.steps {
+		var next []interface{}
+		for _, v := range values {
+			next = append(next, step.apply(root, v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (s jpStep) apply(root, v interface{}) []interface{} {
+	switch s.kind {
+	case jpField:
+		if m, ok := v.(map[string]interface{}); ok {
+			if child, ok := m[s.name]; ok {
+				return []interface{}{child}
+			}
+		}
+	case jpRecursive:
+		var out []interface{}
+		walkJson(v, func(node interface{}) {
+			if m, ok := node.(map[string]interface{}); ok {
+				if child, ok := m[s.name]; ok {
+					out = append(out, child)
+				}
+			}
+		})
+		return out
+	case jpWildcard:
+		return children(v)
+	case jpIndex:
+		if list, ok := v.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				return []interface{}{list[i]}
+			}
+		}
+	case jpSlice:
+		if list, ok := v.([]interface{}); ok {
+			start, end := 0, len(list)
+			if s.start != nil {
+				start = clampIndex(*s.start, len(list))
+			}
+			if s.end != nil {
+				end = clampIndex(*s.end, len(list))
+			}
+			if start < end {
+				return list[start:end]
+			}
+		}
+	case jpFilter:
+		var out []interface{}
+		for _, child := range children(v) {
+			if s.condition.matches(root, child) {
+				out = append(out, child)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func children(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		return t
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, len(keys))
+		for i, k := range keys {
+			out[i] = t[k]
+		}
+		return out
+	}
+	return nil
+}
+
+func walkJson(v interface{}, visit func(interface{})) {
+	visit(v)
+	for _, child := range children(v) {
+		walkJson(child, visit)
+	}
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+func (c *jpCondition) matches(root, v interface{}) bool {
+	left, ok := c.left.value(root, v)
+	if c.op == "" {
+		return ok && truthy(left)
+	}
+	right, _ := c.right.value(root, v)
+	if c.op == "==" || c.op == "!=" {
+		equal := jpEqual(left, right)
+		return equal == (c.op == "==")
+	}
+	if !ok {
+		return false
+	}
+	cmp, ok := jpCompare(left, right)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func (o jpOperand) value(root, v interface{}) (interface{}, bool) {
+	if o.path == nil {
+		return o.literal, true
+	}
+	values := o.path.eval(root, v)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case json.Number:
+		f, _ := t.Float64()
+		return f != 0
+	}
+	return true
+}
+
+func jpEqual(a, b interface{}) bool {
+	if cmp, ok := jpCompare(a, b); ok {
+		return cmp == 0
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// jpCompare compares numbers numerically and strings lexically.
+func jpCompare(a, b interface{}) (int, bool) {
+	fa, aNum := jpNumber(a)
+	fb, bNum := jpNumber(b)
+	if aNum && bNum {
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, aStr := a.(string)
+	sb, bStr := b.(string)
+	if aStr && bStr {
+		return strings.Compare(sa, sb), true
+	}
+	return 0, false
+}
+
+func jpNumber(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	case float64:
+		return t, true
+	}
+	return 0, false
+}
+
+// jpString prints scalars as-is and objects and lists as JSON.
+func jpString(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
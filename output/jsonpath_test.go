@@ -0,0 +1,158 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testJson = `{
+	"cluster": "lab",
+	"guests": [
+		{"vmid": 100, "name": "web-1", "status": "running", "tags": "web", "disk": {"size": 32}},
+		{"vmid": 101, "name": "web-2", "status": "stopped", "tags": "", "disk": {"size": 64}},
+		{"vmid": 200, "name": "db", "status": "running", "template": true}
+	],
+	"labels": {"app.kubernetes.io/name": "gomox", "it's": "quoted"},
+	"min": 101
+}`
+
+func TestJsonPath(t *testing.T) {
+	data, err := toJsonData(json.RawMessage(testJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		// fields
+		{"field", "{.cluster}", "lab"},
+		{"without braces", ".cluster", "lab"},
+		{"from the root", "{$.cluster}", "lab"},
+		{"from the current element", "{@.cluster}", "lab"},
+		{"bare name", "{cluster}", "lab"},
+		{"nested", "{.guests[0].disk.size}", "32"},
+		{"missing key prints nothing", "{.nope}{.guests[0].nope.deeper}", ""},
+		{"object as json", "{.guests[0].disk}", `{"size":32}`},
+		{"text around paths", "cluster: {.cluster}!", "cluster: lab!"},
+		{"string literals", `{.cluster}{"\t"}{'x'}{"}"}{"\n"}`, "lab\tx}\n"},
+		{"spaces inside braces", "{ .cluster }", "lab"},
+
+		// quoted keys
+		{"single-quoted key", "{.labels['app.kubernetes.io/name']}", "gomox"},
+		{"double-quoted key", `{.labels["app.kubernetes.io/name"]}`, "gomox"},
+		{"dot before a quoted key", "{.labels.['app.kubernetes.io/name']}", "gomox"},
+		{"escaped quote in a key", `{.labels['it\'s']}`, "quoted"},
+		{"key with a brace", `{.labels['}']}`, ""},
+
+		// lists
+		{"index", "{.guests[1].name}", "web-2"},
+		{"negative index", "{.guests[-1].name}", "db"},
+		{"index out of range", "{.guests[3].name}", ""},
+		{"wildcard", "{.guests[*].vmid}", "100 101 200"},
+		{"dot wildcard", "{.guests.*.vmid}", "100 101 200"},
+		{"object wildcard in key order", "{.guests[0].disk.*}", "32"},
+		{"slice", "{.guests[0:2].name}", "web-1 web-2"},
+		{"open slice", "{.guests[1:].name}", "web-2 db"},
+		{"slice to the end", "{.guests[:1].name}", "web-1"},
+		{"negative slice", "{.guests[-2:].name}", "web-2 db"},
+		{"slice past the end", "{.guests[1:10].name}", "web-2 db"},
+		{"empty slice", "{.guests[2:1].name}", ""},
+
+		// range
+		{"range", `{range .guests[*]}{.vmid}{"\t"}{.name}{"\n"}{end}`, "100\tweb-1\n101\tweb-2\n200\tdb\n"},
+		{"range over a list", `{range .guests}{.name},{end}`, "web-1,web-2,db,"},
+		{"range with the root", `{range .guests[*]}{$.cluster}/{.name} {end}`, "lab/web-1 lab/web-2 lab/db "},
+		{"nested range", `{range .guests[0:2]}{range .disk.*}{.}{end};{end}`, "32;64;"},
+		{"range over nothing", `{range .nope[*]}x{end}`, ""},
+
+		// recursive descent
+		{"recursive", "{..size}", "32 64"},
+		{"recursive after a path", "{.guests[1]..size}", "64"},
+		{"recursive with index", "{..guests[2].name}", "db"},
+
+		// filters
+		{"filter ==", `{.guests[?(@.status=="running")].name}`, "web-1 db"},
+		{"filter single quotes", `{.guests[?(@.status=='stopped')].name}`, "web-2"},
+		{"filter !=", `{.guests[?(@.status!="running")].name}`, "web-2"},
+		{"filter number", "{.guests[?(@.vmid>100)].name}", "web-2 db"},
+		{"filter <=", "{.guests[?(@.vmid<=101)].name}", "web-1 web-2"},
+		{"filter >=", "{.guests[?(@.vmid >= 200)].name}", "db"},
+		{"filter against the root", "{.guests[?(@.vmid>=$.min)].name}", "web-2 db"},
+		{"filter nested field", "{.guests[?(@.disk.size<64)].name}", "web-1"},
+		{"filter exists", "{.guests[?(@.template)].name}", "db"},
+		{"filter truthy string", "{.guests[?(@.tags)].name}", "web-1"},
+		{"filter true literal", "{.guests[?(@.template==true)].name}", "db"},
+		{"filter missing compares false", "{.guests[?(@.nope>1)].name}", ""},
+		{"filter operator inside a string", `{.guests[?(@.name=="a]b==c")].name}`, ""},
+		{"filter then range", `{range .guests[?(@.status=="running")]}{.vmid} {end}`, "100 200 "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jp, err := ParseJsonPath(tt.template)
+			if err != nil {
+				t.Fatalf("ParseJsonPath(%q) error = %v", tt.template, err)
+			}
+			got, err := jp.Execute(data)
+			if err != nil {
+				t.Fatalf("Execute(%q) error = %v", tt.template, err)
+			}
+			if got != tt.want {
+				t.Errorf("Execute(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJsonPathErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  string
+	}{
+		{"{.name", "unclosed {"},
+		{"{.guests[0}", "unclosed ["},
+		{"{.guests[0].name", "unclosed {"},
+		{"{range .guests[*]}{.name}", "{range} without {end}"},
+		{"{.name}{end}", "unexpected {end}"},
+		{"{range .a}{end}{end}", "unexpected {end}"},
+		{"{.guests[x]}", "bad subscript [x]"},
+		{"{.guests[1:x]}", "bad slice [1:x]"},
+		{"{..}", "expected a name after .."},
+		{`{"\q"}`, "bad string literal"},
+		{"{.guests[?(@.status==running)]}", `bad filter operand "running"`},
+		{"{.labels['a]}", "unclosed {"}, // the quote runs to the end
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			_, err := ParseJsonPath(tt.template)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ParseJsonPath(%q) error = %v, want %q", tt.template, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJsonPathStructs(t *testing.T) {
+	// Printer.printJsonPath runs templates against the `--output json` form
+	type guest struct {
+		VMID uint64 `json:"vmid"`
+		Name string `json:"name"`
+	}
+	data, err := toJsonData([]guest{{100, "web-1"}, {101, "web-2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jp, err := ParseJsonPath(`{range [*]}{.vmid}={.name}{"\n"}{end}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := jp.Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "100=web-1\n101=web-2\n"; got != want {
+		t.Errorf("Execute = %q, want %q", got, want)
+	}
+}
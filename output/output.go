@@ -0,0 +1,354 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TableFormat = "table"
+	JsonFormat  = "json"
+	YamlFormat  = "yaml"
+	CsvFormat   = "csv"
+	TsvFormat   = "tsv"
+)
+
+// Formats lists the supported values of the `--output` flag, besides the
+// TemplateFormats.
+var Formats = []string{TableFormat, JsonFormat, YamlFormat, CsvFormat, TsvFormat}
+
+const timeLayout = "2006-01-02 15:04:05"
+
+/*
+Printer renders command results to stdout.
+
+Results are slices of structs (one row per element), single structs, maps,
+or plain values. Field names in JSON, YAML, CSV and TSV come from the
+`json` struct tags; table headers come from the `table` tag (or the field
+name), and `table:"-"` leaves a column out of tables only.
+
+Go templates are executed against the results themselves (`{{.Name}}`),
+while JSONPath works on their JSON form (`{.name}`).
+*/
+type Printer struct {
+	Format   string
+	Template string // for go-template, go-template-file and jsonpath
+	Writer   io.Writer
+}
+
+// CheckFormat returns an error if format is not a supported output format.
+func CheckFormat(format string) error {
+	if name, arg := splitFormat(format); isTemplateFormat(name) {
+		if arg == "" {
+			return fmt.Errorf("output format %s needs a template, e.g. %s=...", name, name)
+		}
+		return nil
+	}
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, use one of: %s, or %s=...",
+		format, strings.Join(Formats, ", "), strings.Join(TemplateFormats, "=..., "))
+}
+
+// GetPrinter returns a Printer for the `--output` flag.
+func GetPrinter(c *cli.Context) (*Printer, error) {
+	format := c.String("output")
+	if format == "" {
+		format = TableFormat
+	}
+	if err := CheckFormat(format); err != nil {
+		return nil, err
+	}
+	p := &Printer{Format: format, Writer: c.App.Writer}
+	if name, arg := splitFormat(format); isTemplateFormat(name) {
+		p.Format, p.Template = name, arg
+	}
+	return p, nil
+}
+
+// Print renders v in the format selected by the `--output` flag.
+func Print(c *cli.Context, v interface{}) error {
+	p, err := GetPrinter(c)
+	if err != nil {
+		return err
+	}
+	return p.Print(v)
+}
+
+// IsTable reports whether the `--output` flag selects the human-readable table format.
+func IsTable(c *cli.Context) bool {
+	format := c.String("output")
+	return format == "" || format == TableFormat
+}
+
+// Print renders v.
+func (p *Printer) Print(v interface{}) error {
+	switch p.Format {
+	case JsonFormat:
+		enc := json.NewEncoder(p.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case YamlFormat:
+		enc := yaml.NewEncoder(p.Writer)
+		enc.SetIndent(2)
+		defer func() { _ = enc.Close() }()
+		return enc.Encode(v)
+	case CsvFormat:
+		return p.printDelimited(v, ',')
+	case TsvFormat:
+		return p.printDelimited(v, '\t')
+	case GoTemplateFormat, GoTemplateFileFormat:
+		return p.printGoTemplate(v)
+	case JsonPathFormat:
+		return p.printJsonPath(v)
+	default:
+		return p.printTable(v)
+	}
+}
+
+func (p *Printer) printTable(v interface{}) error {
+	rv := indirect(reflect.ValueOf(v))
+	tw := table.NewWriter()
+	tw.Style().Options = table.OptionsNoBordersAndSeparators
+
+	switch {
+	case !rv.IsValid():
+		return nil
+	case rv.Kind() == reflect.Slice && isStruct(rv.Type().Elem()):
+		fields := tableFields(indirectType(rv.Type().Elem()))
+		header := table.Row{}
+		for _, f := range fields {
+			header = append(header, f.header)
+		}
+		tw.AppendHeader(header)
+		for i := 0; i < rv.Len(); i++ {
+			tw.AppendRow(tableRow(indirect(rv.Index(i)), fields))
+		}
+	case rv.Kind() == reflect.Struct:
+		for _, f := range tableFields(rv.Type()) {
+			tw.AppendRow(table.Row{f.header, tableCell(rv.FieldByIndex(f.index))})
+		}
+	case rv.Kind() == reflect.Map:
+		for _, k := range sortedKeys(rv) {
+			tw.AppendRow(table.Row{k.Interface(), tableCell(rv.MapIndex(k))})
+		}
+	case rv.Kind() == reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			tw.AppendRow(table.Row{tableCell(rv.Index(i))})
+		}
+	default:
+		_, err := fmt.Fprintln(p.Writer, tableCell(rv))
+		return err
+	}
+	if tw.Length() == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintln(p.Writer, tw.Render())
+	return err
+}
+
+func (p *Printer) printDelimited(v interface{}, comma rune) error {
+	rv := indirect(reflect.ValueOf(v))
+	w := csv.NewWriter(p.Writer)
+	w.Comma = comma
+
+	var records [][]string
+	switch {
+	case !rv.IsValid():
+		return nil
+	case rv.Kind() == reflect.Slice && isStruct(rv.Type().Elem()):
+		fields := dataFields(indirectType(rv.Type().Elem()))
+		records = append(records, fieldNames(fields))
+		for i := 0; i < rv.Len(); i++ {
+			records = append(records, dataRow(indirect(rv.Index(i)), fields))
+		}
+	case rv.Kind() == reflect.Struct:
+		fields := dataFields(rv.Type())
+		records = append(records, fieldNames(fields), dataRow(rv, fields))
+	case rv.Kind() == reflect.Map:
+		records = append(records, []string{"key", "value"})
+		for _, k := range sortedKeys(rv) {
+			records = append(records, []string{fmt.Sprint(k.Interface()), dataCell(rv.MapIndex(k))})
+		}
+	case rv.Kind() == reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			records = append(records, []string{dataCell(rv.Index(i))})
+		}
+	default:
+		records = append(records, []string{dataCell(rv)})
+	}
+	if err := w.WriteAll(records); err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+type field struct {
+	name   string // stable (json) name
+	header string // table header
+	index  []int
+}
+
+// dataFields returns the fields of t that are serialized to JSON. Like
+// encoding/json, the fields of embedded structs are promoted.
+func dataFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			for _, f := range dataFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		header := sf.Tag.Get("table")
+		if header == "" {
+			header = sf.Name
+		}
+		fields = append(fields, field{name: name, header: header, index: sf.Index})
+	}
+	return fields
+}
+
+// tableFields returns the fields of t shown in tables.
+func tableFields(t reflect.Type) []field {
+	var fields []field
+	for _, f := range dataFields(t) {
+		if f.header != "-" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func fieldNames(fields []field) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+func tableRow(rv reflect.Value, fields []field) table.Row {
+	row := table.Row{}
+	for _, f := range fields {
+		row = append(row, tableCell(rv.FieldByIndex(f.index)))
+	}
+	return row
+}
+
+func dataRow(rv reflect.Value, fields []field) []string {
+	row := make([]string, len(fields))
+	for i, f := range fields {
+		row[i] = dataCell(rv.FieldByIndex(f.index))
+	}
+	return row
+}
+
+// tableCell formats a value for humans, using its String method if it has one.
+func tableCell(rv reflect.Value) interface{} {
+	rv = indirect(rv)
+	if !rv.IsValid() {
+		return ""
+	}
+	switch v := rv.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Local().Format(timeLayout)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if rv.Kind() == reflect.Slice {
+		return joinSlice(rv, func(v reflect.Value) string { return fmt.Sprint(tableCell(v)) })
+	}
+	return rv.Interface()
+}
+
+// dataCell formats a value for machines: numbers stay numbers and times are RFC 3339.
+func dataCell(rv reflect.Value) string {
+	rv = indirect(rv)
+	if !rv.IsValid() {
+		return ""
+	}
+	if t, ok := rv.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprint(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprint(rv.Float())
+	case reflect.Bool:
+		return fmt.Sprint(rv.Bool())
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		return joinSlice(rv, dataCell)
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+func joinSlice(rv reflect.Value, format func(reflect.Value) string) string {
+	parts := make([]string, rv.Len())
+	for i := range parts {
+		parts[i] = format(rv.Index(i))
+	}
+	return strings.Join(parts, ";")
+}
+
+func sortedKeys(rv reflect.Value) []reflect.Value {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.IsValid() && (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isStruct(t reflect.Type) bool {
+	return indirectType(t).Kind() == reflect.Struct && indirectType(t) != reflect.TypeOf(time.Time{})
+}
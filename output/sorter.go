@@ -0,0 +1,100 @@
+package output
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort sorts rows, a slice of structs, by the field named key. The key is
+// the field's JSON name or its table header, compared case-insensitively.
+func Sort(rows interface{}, key string, reverse bool) error {
+	rv := reflect.ValueOf(rows)
+	if rv.Kind() != reflect.Slice || !isStruct(rv.Type().Elem()) {
+		return fmt.Errorf("cannot sort %T", rows)
+	}
+	fields := dataFields(indirectType(rv.Type().Elem()))
+	var sortField *field
+	for i, f := range fields {
+		if strings.EqualFold(f.name, key) || strings.EqualFold(f.header, key) {
+			sortField = &fields[i]
+			break
+		}
+	}
+	if sortField == nil {
+		return fmt.Errorf("cannot sort by %q, use one of: %s", key, strings.Join(fieldNames(fields), ", "))
+	}
+
+	values := make([]reflect.Value, rv.Len())
+	for i := range values {
+		// copy the values, as swapping rows moves what rv.Index refers to
+		if v := indirect(indirect(rv.Index(i)).FieldByIndex(sortField.index)); v.IsValid() {
+			values[i] = reflect.ValueOf(v.Interface())
+		}
+	}
+	swap := reflect.Swapper(rows)
+	sort.Stable(&sorter{values: values, swap: swap, reverse: reverse})
+	return nil
+}
+
+type sorter struct {
+	values  []reflect.Value
+	swap    func(i, j int)
+	reverse bool
+}
+
+func (s *sorter) Len() int { return len(s.values) }
+
+func (s *sorter) Swap(i, j int) {
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+	s.swap(i, j)
+}
+
+func (s *sorter) Less(i, j int) bool {
+	if s.reverse {
+		return compareValues(s.values[j], s.values[i]) < 0
+	}
+	return compareValues(s.values[i], s.values[j]) < 0
+}
+
+// compareValues orders numbers numerically, times chronologically, and
+// everything else by its text, ignoring case.
+func compareValues(a, b reflect.Value) int {
+	if !a.IsValid() || !b.IsValid() {
+		return boolToInt(a.IsValid()) - boolToInt(b.IsValid())
+	}
+	if ta, ok := a.Interface().(time.Time); ok {
+		tb := b.Interface().(time.Time)
+		return ta.Compare(tb)
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	case reflect.Bool:
+		return boolToInt(a.Bool()) - boolToInt(b.Bool())
+	}
+	return strings.Compare(strings.ToLower(dataCell(a)), strings.ToLower(dataCell(b)))
+}
+
+func compareOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
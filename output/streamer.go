@@ -0,0 +1,140 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Stream prints rows as they come in, for commands that follow changes
+(`--follow`). Tables and CSV/TSV print their header once, JSON is printed as
+one compact object per line (JSON Lines), YAML as one document per row, and
+templates are executed once per row.
+
+Table columns can't be sized ahead of time, so they widen as longer values
+come in.
+*/
+type Stream struct {
+	p       *Printer
+	started bool
+	widths  []int
+}
+
+// NewStream returns a Stream in the format selected by the `--output` flag.
+func NewStream(c *cli.Context) (*Stream, error) {
+	p, err := GetPrinter(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{p: p}, nil
+}
+
+// Print prints rows, a slice of structs.
+func (s *Stream) Print(rows interface{}) error {
+	rv := indirect(reflect.ValueOf(rows))
+	if !rv.IsValid() || rv.Kind() != reflect.Slice || !isStruct(rv.Type().Elem()) {
+		return fmt.Errorf("can only stream slices of structs, not %T", rows)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := s.printRow(indirect(rv.Index(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Stream) printRow(rv reflect.Value) error {
+	defer func() { s.started = true }()
+	switch s.p.Format {
+	case JsonFormat:
+		return json.NewEncoder(s.p.Writer).Encode(rv.Interface())
+	case YamlFormat:
+		if _, err := fmt.Fprintln(s.p.Writer, "---"); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(s.p.Writer)
+		enc.SetIndent(2)
+		if err := enc.Encode(rv.Interface()); err != nil {
+			return err
+		}
+		return enc.Close()
+	case CsvFormat:
+		return s.printDelimited(rv, ',')
+	case TsvFormat:
+		return s.printDelimited(rv, '\t')
+	case GoTemplateFormat, GoTemplateFileFormat, JsonPathFormat:
+		return s.p.Print(rv.Interface())
+	default:
+		return s.printTableRow(rv)
+	}
+}
+
+func (s *Stream) printDelimited(rv reflect.Value, comma rune) error {
+	w := csv.NewWriter(s.p.Writer)
+	w.Comma = comma
+	fields := dataFields(rv.Type())
+	if !s.started {
+		if err := w.Write(fieldNames(fields)); err != nil {
+			return err
+		}
+	}
+	if err := w.Write(dataRow(rv, fields)); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// printTableRow prints a row laid out like printTable's tables.
+func (s *Stream) printTableRow(rv reflect.Value) error {
+	fields := tableFields(rv.Type())
+	cells := make([]string, len(fields))
+	headers := make([]string, len(fields))
+	right := make([]bool, len(fields))
+	if s.widths == nil {
+		s.widths = make([]int, len(fields))
+	}
+	for i, f := range fields {
+		v := rv.FieldByIndex(f.index)
+		cells[i] = fmt.Sprint(tableCell(v))
+		headers[i] = strings.ToUpper(f.header)
+		right[i] = isNumber(indirect(v))
+		s.widths[i] = max(s.widths[i], len(cells[i]), len(headers[i]))
+	}
+
+	var b strings.Builder
+	if !s.started {
+		s.writeTableLine(&b, headers, make([]bool, len(fields)))
+	}
+	s.writeTableLine(&b, cells, right)
+	_, err := fmt.Fprint(s.p.Writer, b.String())
+	return err
+}
+
+func (s *Stream) writeTableLine(b *strings.Builder, cells []string, right []bool) {
+	for i, cell := range cells {
+		if right[i] {
+			fmt.Fprintf(b, " %*s ", s.widths[i], cell)
+		} else {
+			fmt.Fprintf(b, " %-*s ", s.widths[i], cell)
+		}
+	}
+	b.WriteString("\n")
+}
+
+func isNumber(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		_, isStringer := rv.Interface().(fmt.Stringer)
+		return !isStringer
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	GoTemplateFormat     = "go-template"
+	GoTemplateFileFormat = "go-template-file"
+	JsonPathFormat       = "jsonpath"
+)
+
+// TemplateFormats are the formats that take an argument (`-o jsonpath=...`).
+var TemplateFormats = []string{GoTemplateFormat, GoTemplateFileFormat, JsonPathFormat}
+
+// splitFormat splits `go-template=TEMPLATE` into its format and template.
+func splitFormat(format string) (string, string) {
+	name, arg, _ := strings.Cut(format, "=")
+	return name, arg
+}
+
+func isTemplateFormat(name string) bool {
+	for _, f := range TemplateFormats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateFuncs are the helper functions available to go-templates.
+var TemplateFuncs = template.FuncMap{
+	"bytes":    templateBytes,
+	"duration": templateDuration,
+	"json":     templateJson,
+	"join":     strings.Join,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// templateBytes formats a size in bytes, e.g. `{{bytes .MaxMem}}`.
+func templateBytes(v interface{}) (string, error) {
+	n, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	return FormatBytes(uint64(n)), nil
+}
+
+// templateDuration formats a duration, given as a time.Duration or in
+// seconds, e.g. `{{duration .Uptime}}`.
+func templateDuration(v interface{}) (string, error) {
+	if d, ok := v.(time.Duration); ok {
+		return FormatDuration(d), nil
+	}
+	n, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	return FormatDuration(time.Duration(n * float64(time.Second))), nil
+}
+
+func templateJson(v interface{}) (string, error) {
+	out, err := json.Marshal(v)
+	return string(out), err
+}
+
+func toFloat(v interface{}) (float64, error) {
+	rv := indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return strconv.ParseFloat(rv.String(), 64)
+	}
+	return 0, fmt.Errorf("expected a number, got %T", v)
+}
+
+// printGoTemplate executes a text/template against v.
+func (p *Printer) printGoTemplate(v interface{}) error {
+	text := p.Template
+	if p.Format == GoTemplateFileFormat {
+		data, err := os.ReadFile(p.Template)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("output").Funcs(TemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid go-template: %w", err)
+	}
+	return tmpl.Execute(p.Writer, v)
+}
+
+// printJsonPath evaluates a JSONPath template against the JSON form of v.
+func (p *Printer) printJsonPath(v interface{}) error {
+	jp, err := ParseJsonPath(p.Template)
+	if err != nil {
+		return err
+	}
+	data, err := toJsonData(v)
+	if err != nil {
+		return err
+	}
+	out, err := jp.Execute(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(p.Writer, out)
+	return err
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	Byte     = 1
+	Kilobyte = 1024 * Byte
+	Megabyte = 1024 * Kilobyte
+	Gigabyte = 1024 * Megabyte
+	Terabyte = 1024 * Gigabyte
+)
+
+// Bytes is a size in bytes. Tables show it human-readable, every other
+// format as a plain number.
+type Bytes uint64
+
+func (b Bytes) String() string {
+	return FormatBytes(uint64(b))
+}
+
+// FormatBytes formats a size in bytes using binary (KiB, MiB, ...) units.
+func FormatBytes(b uint64) string {
+	switch {
+	case b >= Terabyte:
+		return fmt.Sprintf("%.1f TiB", float64(b)/Terabyte)
+	case b >= Gigabyte:
+		return fmt.Sprintf("%.1f GiB", float64(b)/Gigabyte)
+	case b >= Megabyte:
+		return fmt.Sprintf("%.1f MiB", float64(b)/Megabyte)
+	case b >= Kilobyte:
+		return fmt.Sprintf("%.1f KiB", float64(b)/Kilobyte)
+	default:
+		return fmt.Sprintf("%d B", b)
+	}
+}
+
+// Seconds is a duration in whole seconds, like the uptimes the API reports.
+// Tables show it human-readable, every other format as a plain number.
+type Seconds uint64
+
+func (s Seconds) String() string {
+	return FormatDuration(time.Duration(s) * time.Second)
+}
+
+// FormatDuration formats a duration as e.g. `3d 4h`, `2h 5m`, or `42s`.
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	seconds := (d % time.Minute) / time.Second
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+// Percent is a percentage. Tables show it with one decimal, every other
+// format as a plain number.
+type Percent float64
+
+func (p Percent) String() string {
+	return fmt.Sprintf("%.1f%%", float64(p))
+}
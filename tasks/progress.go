@@ -0,0 +1,194 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+const progressRedraw = 200 * time.Millisecond
+
+/*
+WaitAll waits for all of tasks to stop running, like WaitTask does for one.
+Unless quiet, the progress is shown with one line per task, with its status
+and latest log line.
+
+The tasks are waited for concurrently, and a failure to wait for one doesn't
+stop the others; the errors are joined. As with WaitTask, the tasks aren't
+updated after they stop, and a TaskTimeoutError is returned for each task
+still running after the timeout set with WithTimeout.
+*/
+func WaitAll(ctx context.Context, tasks []*proxmox.Task, opts ...WaitOption) error {
+	return errors.Join(WaitEach(ctx, tasks, opts...)...)
+}
+
+// WaitEach is WaitAll, but returns the error of waiting for each task, in
+// the order of tasks.
+func WaitEach(ctx context.Context, tasks []*proxmox.Task, opts ...WaitOption) []error {
+	c := newWaitConfig(opts...)
+
+	waitCtx, cancel := c.pollingConfig.withTimeout(ctx)
+	defer cancel()
+
+	p := newProgress(logrus.StandardLogger().Out, tasks)
+	if !c.quiet {
+		p.start()
+		defer p.stop()
+	}
+
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task *proxmox.Task) {
+			defer wg.Done()
+			err := follow(waitCtx, task, c.pollingConfig.pollDuration, func(ln string) {
+				p.update(i, ln)
+			})
+			if err != nil {
+				errs[i] = waitError(ctx, waitCtx, task, c.pollingConfig, err)
+			}
+			p.finish(i, errs[i])
+		}(i, task)
+	}
+	wg.Wait()
+	return errs
+}
+
+// progress renders the state of several tasks. On a terminal, it redraws a
+// line per task in place; otherwise it prints a line as each task finishes.
+type progress struct {
+	mu    sync.Mutex
+	out   io.Writer
+	tty   bool
+	rows  []progressRow
+	drawn int // lines drawn by the last redraw
+
+	done    chan struct{}
+	stopped chan struct{}
+	running bool
+}
+
+type progressRow struct {
+	task   *proxmox.Task
+	status string
+	msg    string
+}
+
+func newProgress(out io.Writer, tasks []*proxmox.Task) *progress {
+	p := &progress{
+		out:     out,
+		rows:    make([]progressRow, len(tasks)),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if f, ok := out.(*os.File); ok {
+		p.tty = term.IsTerminal(int(f.Fd()))
+	}
+	for i, task := range tasks {
+		p.rows[i] = progressRow{task: task, status: proxmox.TaskRunning}
+	}
+	return p
+}
+
+// start starts showing the progress, until stop is called.
+func (p *progress) start() {
+	p.running = true
+	if !p.tty {
+		close(p.stopped)
+		return
+	}
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(progressRedraw)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.redraw()
+			case <-p.done:
+				p.redraw()
+				return
+			}
+		}
+	}()
+}
+
+func (p *progress) stop() {
+	close(p.done)
+	<-p.stopped
+}
+
+// update records ln as the latest log line of the i-th task.
+func (p *progress) update(i int, ln string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rows[i].msg = ln
+}
+
+// finish records the outcome of waiting for the i-th task.
+func (p *progress) finish(i int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	row := &p.rows[i]
+	switch {
+	case errors.Is(err, ErrTaskTimeout):
+		row.status = "timeout"
+	case err != nil:
+		row.status = "error"
+		row.msg = err.Error()
+	case row.task.IsFailed:
+		row.status = "failed"
+	default:
+		row.status = "ok"
+	}
+	if row.msg == "" {
+		row.msg = genericMsg(*row.task)
+	}
+	if p.running && !p.tty {
+		fmt.Fprintln(p.out, p.line(*row, 0))
+	}
+}
+
+func (p *progress) redraw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	width := 0
+	if f, ok := p.out.(*os.File); ok {
+		width, _, _ = term.GetSize(int(f.Fd()))
+	}
+
+	var b strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", p.drawn) // back to the first line
+	}
+	for _, row := range p.rows {
+		b.WriteString("\r\x1b[K")
+		b.WriteString(p.line(row, width))
+		b.WriteString("\n")
+	}
+	p.drawn = len(p.rows)
+	_, _ = io.WriteString(p.out, b.String())
+}
+
+// line formats row, cut to width (if non-zero) so it fits on one line.
+func (p *progress) line(row progressRow, width int) string {
+	upidWidth := 0
+	for _, r := range p.rows {
+		upidWidth = max(upidWidth, len(r.task.UPID))
+	}
+	ln := fmt.Sprintf("%-*s  %-7s  %s", upidWidth, row.task.UPID, row.status, row.msg)
+	if runes := []rune(ln); width > 0 && len(runes) >= width {
+		ln = string(runes[:width-1])
+	}
+	return ln
+}
@@ -2,8 +2,11 @@ package tasks
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -18,6 +21,9 @@ const (
 	DefaultSpinnerCharSet = 9 // Classic Unix quiet |/-\|
 	// DefaultSpinnerCharSet = 14 // Docker Compose-style Braille spinner
 	spinnerSpeed = 100 * time.Millisecond
+	stopTimeout  = 10 * time.Second // for stopping a task that timed out
+	logPageSize  = 50               // log lines to request at once
+	readPageSize = 500              // log lines to request at once by ReadLog
 )
 
 type spinnerConfig struct {
@@ -38,6 +44,7 @@ func WithSpinnerSpeed(speed time.Duration) SpinnerOption {
 
 type waitConfig struct {
 	quiet         bool
+	logLines      bool
 	spinnerConfig spinnerConfig
 	pollingConfig pollingConfig
 }
@@ -53,6 +60,12 @@ func WithOutput() WaitOption {
 	return func(c *waitConfig) { c.quiet = false }
 }
 
+// WithLogLines prints every line of the task log as it is written, rather
+// than only the latest one. Don't combine it with WithSpinner.
+func WithLogLines() WaitOption {
+	return func(c *waitConfig) { c.quiet = false; c.logLines = true }
+}
+
 func WithSpinner(opts ...SpinnerOption) WaitOption {
 	s := &spinnerConfig{
 		charSet: DefaultSpinnerCharSet,
@@ -103,7 +116,7 @@ func Watch(ctx context.Context, start int, t *proxmox.Task) (chan string, error)
 	logrus.Debugf("starting watcher on %s", t.UPID)
 	watch := make(chan string)
 
-	log, err := t.Log(ctx, start, 50)
+	log, err := t.Log(ctx, start, logPageSize)
 	if err != nil {
 		return watch, err
 	}
@@ -114,9 +127,11 @@ func Watch(ctx context.Context, start int, t *proxmox.Task) (chan string, error)
 		if len(log) > 0 {
 			break
 		}
-		time.Sleep(1 * time.Second)
+		if err := sleep(ctx, 1*time.Second); err != nil {
+			return watch, err
+		}
 
-		log, err = t.Log(ctx, start, 50)
+		log, err = t.Log(ctx, start, logPageSize)
 		if err != nil {
 			return watch, err
 		}
@@ -125,15 +140,17 @@ func Watch(ctx context.Context, start int, t *proxmox.Task) (chan string, error)
 	if len(log) == 0 {
 		return watch, fmt.Errorf("no logs available for %s", t.UPID)
 	}
+	lines := LogLines(log)
 
 	go func() {
+		defer close(watch)
 		logrus.Debugf("logs found for task %s", t.UPID)
-		for _, ln := range log {
-			watch <- ln
+		if !send(ctx, watch, lines) {
+			return
 		}
 		logrus.Debugf("watching task %s", t.UPID)
-		err := tasktail(ctx, len(log), watch, t)
-		if err != nil {
+		err := tasktail(ctx, start+len(lines), watch, t)
+		if err != nil && ctx.Err() == nil {
 			logrus.Errorf("error watching logs: %s", err)
 		}
 	}()
@@ -142,40 +159,173 @@ func Watch(ctx context.Context, start int, t *proxmox.Task) (chan string, error)
 	return watch, nil
 }
 
+// tasktail sends the task's log to watch, from line start, until the task
+// stops running or ctx is done.
 func tasktail(ctx context.Context, start int, watch chan string, task *proxmox.Task) error {
 	for {
 		logrus.Debugf("tailing log for task %s", task.UPID)
 		if err := task.Ping(ctx); err != nil {
 			return err
 		}
-
-		if task.Status != proxmox.TaskRunning {
+		running := task.Status == proxmox.TaskRunning
+
+		// read the log after the ping, so the last lines aren't missed, and
+		// page by page until it is caught up
+		for {
+			log, err := task.Log(ctx, start, logPageSize)
+			if err != nil {
+				return err
+			}
+			lines := LogLines(log)
+			if !send(ctx, watch, lines) {
+				return ctx.Err()
+			}
+			start = start + len(lines)
+			if len(lines) < logPageSize {
+				break
+			}
+		}
+		if !running {
 			logrus.Debugf("task %s is no longer running, closing down watcher", task.UPID)
-			close(watch)
 			return nil
 		}
+		if err := sleep(ctx, DefaultPollDuration); err != nil {
+			return err
+		}
+	}
+}
 
-		logs, err := task.Log(ctx, start, 50)
+// LogLines returns the lines of a task log in order, leaving out the
+// NoContent placeholder of an empty log.
+func LogLines(log proxmox.Log) []string {
+	n := make([]int, 0, len(log))
+	for i, ln := range log {
+		if ln != NoContent {
+			n = append(n, i)
+		}
+	}
+	sort.Ints(n)
+	lines := make([]string, len(n))
+	for i := range n {
+		lines[i] = log[n[i]]
+	}
+	return lines
+}
+
+// ReadLog returns the whole log of task, as far as it has been written.
+func ReadLog(ctx context.Context, task *proxmox.Task) ([]string, error) {
+	var lines []string
+	for {
+		log, err := task.Log(ctx, len(lines), readPageSize)
 		if err != nil {
+			return lines, err
+		}
+		page := LogLines(log)
+		lines = append(lines, page...)
+		if len(page) < readPageSize {
+			return lines, nil
+		}
+	}
+}
+
+// WriteLog writes the whole log of task to w, a line at a time.
+func WriteLog(ctx context.Context, w io.Writer, task *proxmox.Task) error {
+	lines, err := ReadLog(ctx, task)
+	if err != nil {
+		return err
+	}
+	for _, ln := range lines {
+		if _, err := fmt.Fprintln(w, ln); err != nil {
 			return err
 		}
-		for _, ln := range logs {
-			watch <- ln
+	}
+	return nil
+}
+
+// send sends lines to watch, giving up if ctx is done first.
+func send(ctx context.Context, watch chan string, lines []string) bool {
+	for _, ln := range lines {
+		select {
+		case watch <- ln:
+		case <-ctx.Done():
+			return false
 		}
-		start = start + len(logs)
-		time.Sleep(DefaultPollDuration)
 	}
+	return true
 }
 
+// sleep waits for d, or returns ctx's error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+WaitTask waits for task to stop running, showing its progress as configured
+by opts. The task isn't updated after it stops; Ping it for its exit status.
+
+The wait ends early if ctx is done, e.g. on SIGINT, or after the timeout set
+with WithTimeout, in which case a TaskTimeoutError is returned and the task
+is stopped if that was requested.
+*/
 func WaitTask(ctx context.Context, task *proxmox.Task, opts ...WaitOption) (err error) {
-	taskhead, err := task.Log(ctx, 0, 1)
+	c := newWaitConfig(opts...)
+
+	// set up the timeout
+	waitCtx, cancel := c.pollingConfig.withTimeout(ctx)
+	defer cancel()
+
+	if !c.logLines {
+		taskhead, err := task.Log(waitCtx, 0, 1)
+		if err != nil {
+			return waitError(ctx, waitCtx, task, c.pollingConfig, err)
+		}
+		if len(taskhead) > 0 && taskhead[0] != NoContent {
+			logrus.Infoln(taskhead[0])
+		}
+	}
+
+	// set up the spinner
+	s := spinner.New(spinner.CharSets[c.spinnerConfig.charSet], c.spinnerConfig.speed)
+	if c.spinnerConfig.enabled {
+		s.Enable()
+	} else {
+		s.Disable()
+	}
+	s.Start()
+	defer s.Stop()
+
+	var msg string
+	err = follow(waitCtx, task, c.pollingConfig.pollDuration, func(ln string) {
+		if c.logLines {
+			logrus.Infof("%s\n", ln)
+		}
+		newMsg := taskMsgPrefix(*task, ln)
+		if msg != newMsg {
+			msg = newMsg
+			logrus.Debugln(msg)
+			s.Suffix = " " + msg
+		}
+	})
 	if err != nil {
-		return err
+		return waitError(ctx, waitCtx, task, c.pollingConfig, err)
+	}
+	if msg == "" {
+		msg = taskMsgPrefix(*task, NoContent)
 	}
-	taskname := taskhead[0]
-	if taskname != NoContent {
-		fmt.Println(taskname)
+	if !c.logLines {
+		logrus.Infoln(msg)
 	}
+	return nil
+}
+
+func newWaitConfig(opts ...WaitOption) *waitConfig {
 	c := &waitConfig{
 		quiet: true, // default to quiet
 		spinnerConfig: spinnerConfig{
@@ -191,62 +341,77 @@ func WaitTask(ctx context.Context, task *proxmox.Task, opts ...WaitOption) (err
 	for _, opt := range opts {
 		opt(c)
 	}
-	watch, err := task.Watch(ctx, 0)
-	if err != nil {
-		return err
-	}
-
-	// set up the spinner
-	s := spinner.New(spinner.CharSets[c.spinnerConfig.charSet], c.spinnerConfig.speed)
-	if c.spinnerConfig.enabled {
-		s.Enable()
-	} else {
-		s.Disable()
-	}
-	s.Start()
-	defer s.Stop()
+	return c
+}
 
-	// set up the timeout
-	if c.pollingConfig.timeout > 0 { // timeout == 0 means never timeout
-		go func() {
-			timeout := task.Wait(ctx, c.pollingConfig.pollDuration, c.pollingConfig.timeout)
-			proxmox.IsTimeout(timeout)
-			if c.pollingConfig.stopTaskOnTimeout {
-				err := task.Stop(ctx)
-				if err != nil {
-					return
-				}
-			}
-		}()
+// withTimeout returns a context that is done after the configured timeout.
+func (c pollingConfig) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 { // timeout == 0 means never timeout
+		return context.WithTimeout(ctx, c.timeout)
 	}
+	return context.WithCancel(ctx)
+}
 
-	var (
-		msg    string
-		newMsg string
-	)
-
+// follow updates task every pollDuration until it stops running, calling
+// onLine with each new line of its log.
+func follow(ctx context.Context, task *proxmox.Task, pollDuration time.Duration, onLine func(string)) error {
+	start := 0
 	for {
-		select {
-		// receive new task data
-		case ln, ok := <-watch:
-			if !ok {
-				watch = nil
-				break
-			}
-			newMsg = taskMsgPrefix(*task, ln)
-			if msg != newMsg {
-				msg = newMsg
-				logrus.Debugln(msg)
-				s.Suffix = " " + msg
-			}
+		if err := task.Ping(ctx); err != nil {
+			return err
 		}
-		if watch == nil {
-			logrus.Infoln(msg)
-			break
+		running := task.Status == proxmox.TaskRunning
+
+		// read the log after the ping, so the last lines aren't missed
+		log, err := task.Log(ctx, start, logPageSize)
+		if err != nil {
+			return err
+		}
+		lines := LogLines(log)
+		start += len(lines)
+		for _, ln := range lines {
+			onLine(ln)
+		}
+		switch {
+		case len(lines) == logPageSize:
+			continue // there may be more already
+		case !running:
+			return nil
 		}
+		if err := sleep(ctx, pollDuration); err != nil {
+			return err
+		}
+	}
+}
 
+// waitError returns the error for a wait on task that ended with err. If the
+// wait timed out, rather than ctx being done, the task is stopped as
+// configured and a TaskTimeoutError is returned.
+func waitError(
+	ctx context.Context,
+	waitCtx context.Context,
+	task *proxmox.Task,
+	c pollingConfig,
+	err error,
+) error {
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("stopped waiting for task %s, which may still be running: %w", task.UPID, ctx.Err())
+	case waitCtx.Err() == nil:
+		return err
 	}
-	return nil
+
+	timeoutErr := &TaskTimeoutError{UPID: task.UPID, Timeout: c.timeout}
+	if c.stopTaskOnTimeout {
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+		defer cancel()
+		if err := task.Stop(stopCtx); err != nil {
+			logrus.Errorf("failed to stop task %s: %s\n", task.UPID, err)
+		} else {
+			timeoutErr.Stopped = true
+		}
+	}
+	return timeoutErr
 }
 
 func taskMsgPrefix(task proxmox.Task, msg string) string {
@@ -269,17 +434,90 @@ func genericMsg(task proxmox.Task) string {
 	return taskMsg
 }
 
+// ErrTaskFailed is wrapped by the errors returned for tasks that finished unsuccessfully.
+var ErrTaskFailed = errors.New("the task has failed")
+
+// TaskFailedError is returned for a task that finished unsuccessfully.
+type TaskFailedError struct {
+	UPID       proxmox.UPID
+	ExitStatus string
+}
+
+func (e *TaskFailedError) Error() string {
+	return fmt.Sprintf("task %s failed: %s", e.UPID, e.ExitStatus)
+}
+
+func (e *TaskFailedError) Unwrap() error {
+	return ErrTaskFailed
+}
+
+// CheckFailed returns a TaskFailedError if the task failed, as of its last update.
+func CheckFailed(task *proxmox.Task) error {
+	if task.IsFailed {
+		return &TaskFailedError{UPID: task.UPID, ExitStatus: task.ExitStatus}
+	}
+	return nil
+}
+
+// ErrTaskTimeout is wrapped by the errors returned when waiting for a task times out.
+var ErrTaskTimeout = errors.New("timed out waiting for the task")
+
+// TaskTimeoutError is returned when waiting for a task times out.
+type TaskTimeoutError struct {
+	UPID    proxmox.UPID
+	Timeout time.Duration
+	Stopped bool // whether the task was stopped, see WithTimeout
+}
+
+func (e *TaskTimeoutError) Error() string {
+	state := "it is still running"
+	if e.Stopped {
+		state = "it was stopped"
+	}
+	return fmt.Sprintf("timed out after %s waiting for task %s; %s", e.Timeout, e.UPID, state)
+}
+
+func (e *TaskTimeoutError) Unwrap() error {
+	return ErrTaskTimeout
+}
+
 // TaskStatus updates the task and returns a message explaining the task's
 // status
-func TaskStatus(ctx context.Context, task proxmox.Task) (string, error) {
+func TaskStatus(ctx context.Context, task *proxmox.Task) (string, error) {
 	err := task.Ping(ctx) // Update task.
-	msg := genericMsg(task)
-	if task.IsFailed {
-		err = fmt.Errorf("the task has failed")
+	msg := genericMsg(*task)
+	if err == nil {
+		err = CheckFailed(task)
 	}
 	return msg, err
 }
 
+// Summary is the printable state of a task.
+type Summary struct {
+	UPID       proxmox.UPID `json:"upid" table:"UPID"`
+	Node       string       `json:"node"`
+	Type       string       `json:"type"`
+	ID         string       `json:"id" table:"ID"`
+	User       string       `json:"user"`
+	Status     string       `json:"status"`
+	ExitStatus string       `json:"exitstatus" table:"Exit Status"`
+	StartTime  time.Time    `json:"starttime" table:"Started"`
+}
+
+// NewSummary returns the printable state of a task, as of its last update.
+func NewSummary(task *proxmox.Task) Summary {
+	return Summary{
+		UPID:       task.UPID,
+		Node:       task.Node,
+		Type:       task.Type,
+		ID:         task.ID,
+		User:       task.User,
+		Status:     task.Status,
+		ExitStatus: task.ExitStatus,
+		StartTime:  task.StartTime,
+	}
+}
+
 func GetWaitCmd(task proxmox.Task) string {
 	return fmt.Sprintf(
 		`
@@ -0,0 +1,116 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/urfave/cli/v2"
+)
+
+// AuthMethod is the way gomox authenticates against the API.
+type AuthMethod string
+
+const (
+	PasswordAuth = AuthMethod("password")
+	TokenAuth    = AuthMethod("token")
+	TicketAuth   = AuthMethod("ticket") // a ticket cached by an earlier login
+)
+
+const tokenSeparator = "!" // user@realm!tokenname
+
+func NoAuthError() error {
+	return fmt.Errorf(
+		"no credentials supplied: use --pveuser/--pvepassword (PVE_USER/PVE_PASSWORD), " +
+			"--token-id/--token-secret (PVE_TOKEN_ID/PVE_TOKEN_SECRET), " +
+			"or `gomox login` first",
+	)
+}
+
+func ConflictingAuthError() error {
+	return fmt.Errorf(
+		"both password and API token credentials supplied: " +
+			"use either --pveuser/--pvepassword or --token-id/--token-secret, not both",
+	)
+}
+
+// GetAuthMethod decides which authentication method the global flags ask for.
+// Supplying both a password and a token is an error, and so is supplying
+// neither unless a ticket from an earlier login is still cached.
+func GetAuthMethod(c *cli.Context) (AuthMethod, error) {
+	hasPassword := c.String("pvepassword") != ""
+	hasToken := c.String("token-id") != "" || c.String("token-secret") != ""
+
+	switch {
+	case hasPassword && hasToken:
+		return "", ConflictingAuthError()
+	case hasToken:
+		return TokenAuth, nil
+	case hasPassword:
+		return PasswordAuth, nil
+	case GetCachedTicket(c) != nil:
+		return TicketAuth, nil
+	default:
+		return "", NoAuthError()
+	}
+}
+
+// GetCredentials returns the username/password credentials from the global flags.
+func GetCredentials(c *cli.Context) proxmox.Credentials {
+	return proxmox.Credentials{
+		Username: c.String("pveuser"),
+		Password: c.String("pvepassword"),
+		Realm:    c.String("pverealm"),
+	}
+}
+
+// GetAuthOption returns the client option for whichever authentication
+// method was requested. Password logins go through the ticket cache, so this
+// may log in using httpClient, and httpClient is set up to replace a cached
+// ticket the server rejects (see ticketRefresher).
+func GetAuthOption(c *cli.Context, httpClient *http.Client) (proxmox.Option, error) {
+	method, err := GetAuthMethod(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case TokenAuth:
+		tokenId, tokenSecret := c.String("token-id"), c.String("token-secret")
+		if err := CheckToken(tokenId, tokenSecret); err != nil {
+			return nil, err
+		}
+		return proxmox.WithAPIToken(tokenId, tokenSecret), nil
+	case PasswordAuth:
+		if c.String("pveuser") == "" {
+			return nil, fmt.Errorf("a password was supplied without a username (--pveuser/PVE_USER)")
+		}
+	default:
+		if c.String("pveuser") == "" {
+			return nil, fmt.Errorf("no username (--pveuser/PVE_USER) and no cached ticket, run `gomox login` first")
+		}
+	}
+	ticket, err := GetSessionTicket(c, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	loginClient := *httpClient
+	httpClient.Transport = newTicketRefresher(c, &loginClient, ticket)
+	return proxmox.WithSession(ticket.Ticket, ticket.CSRFPreventionToken), nil
+}
+
+// CheckToken validates an API token ID (`user@realm!tokenname`) and secret.
+func CheckToken(tokenId, tokenSecret string) error {
+	switch {
+	case tokenId == "":
+		return fmt.Errorf("an API token secret was supplied without a token ID (--token-id/PVE_TOKEN_ID)")
+	case tokenSecret == "":
+		return fmt.Errorf("an API token ID was supplied without a secret (--token-secret/PVE_TOKEN_SECRET)")
+	}
+	user, name, found := strings.Cut(tokenId, tokenSeparator)
+	if !found || name == "" || !strings.Contains(user, "@") {
+		return fmt.Errorf("invalid API token ID %q: expected the form user@realm!tokenname", tokenId)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/output"
+	"github.com/perchnet/gomox/tasks"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	ResultOk      = "ok"
+	ResultSkipped = "skipped"
+	ResultStarted = "started" // without --wait
+	ResultFailed  = "failed"
+)
+
+// GuestAction performs an operation on a VM. It returns a nil task when
+// there was nothing to do, e.g. the VM was already in the requested state.
+type GuestAction func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error)
+
+// GuestResult is the outcome of a GuestAction on one guest.
+type GuestResult struct {
+	VMID    uint64       `json:"vmid" table:"VMID"`
+	Name    string       `json:"name"`
+	Node    string       `json:"node"`
+	Result  string       `json:"result"`
+	UPID    proxmox.UPID `json:"upid,omitempty" table:"-"`
+	Message string       `json:"message,omitempty"`
+	err     error
+}
+
+// ParallelFlag returns the `--parallel` flag of the commands that send API
+// requests in parallel, such as RunBulkCli.
+func ParallelFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:  "parallel",
+		Usage: "send at most `N` API requests at once",
+		Value: DefaultParallel,
+	}
+}
+
+/*
+RunBulk runs action on each guest, at most parallel at a time. With wait, it
+then waits for the resulting tasks together with tasks.WaitEach, as
+configured by opts; otherwise the guests with a task are ResultStarted.
+Failures are recorded in the results rather than stopping the other guests;
+the results are in the order of guests.
+*/
+func RunBulk(
+	ctx context.Context,
+	client proxmox.Client,
+	guests []*proxmox.ClusterResource,
+	parallel int,
+	action GuestAction,
+	wait bool,
+	opts ...tasks.WaitOption,
+) []GuestResult {
+	results := make([]GuestResult, len(guests))
+	started := make([]*proxmox.Task, len(guests))
+	_ = RunParallel(ctx, len(guests), parallel, func(ctx context.Context, i int) error {
+		rs := guests[i]
+		results[i] = GuestResult{VMID: rs.VMID, Name: rs.Name, Node: rs.Node}
+		vm, err := GetVirtualMachine(ctx, client, rs)
+		if err != nil {
+			results[i].fail(err)
+			return nil
+		}
+		task, err := action(ctx, vm)
+		if err != nil {
+			results[i].fail(err)
+			return nil
+		}
+		if task == nil {
+			results[i].Result = ResultSkipped
+			return nil
+		}
+		results[i].Result, results[i].UPID = ResultStarted, task.UPID
+		started[i] = task
+		return nil
+	})
+	if !wait {
+		return results
+	}
+
+	var (
+		ts      []*proxmox.Task
+		indexes []int
+	)
+	for i, task := range started {
+		if task != nil {
+			ts = append(ts, task)
+			indexes = append(indexes, i)
+		}
+	}
+	for j, err := range tasks.WaitEach(ctx, ts, opts...) {
+		task, result := ts[j], &results[indexes[j]]
+		if err != nil {
+			result.fail(err)
+			continue
+		}
+		if err := task.Ping(ctx); err != nil {
+			result.fail(WrapApiError(err))
+			continue
+		}
+		if err := tasks.CheckFailed(task); err != nil {
+			result.Result, result.Message, result.err = ResultFailed, task.ExitStatus, err
+			continue
+		}
+		result.Result = ResultOk
+	}
+	return results
+}
+
+// fail records err as the outcome for the guest.
+func (r *GuestResult) fail(err error) {
+	r.Result, r.Message, r.err = ResultFailed, err.Error(), err
+}
+
+// bulkError reports the guests that failed in RunBulkCli. It wraps their
+// errors, so ExitCode can tell failed tasks from API errors.
+type bulkError struct {
+	errs  []error
+	total int
+}
+
+func (e *bulkError) Error() string {
+	return fmt.Sprintf("%d of %d guests failed", len(e.errs), e.total)
+}
+
+func (e *bulkError) Unwrap() []error {
+	return e.errs
+}
+
+// RunBulkCli runs RunBulk with the `--parallel`, `--wait` and
+// `--wait-timeout` flags, showing the combined progress unless `--quiet`,
+// prints the results, saves the task logs if `--log-file` is set, and
+// returns an error if any guest failed.
+func RunBulkCli(
+	c *cli.Context,
+	client proxmox.Client,
+	guests []*proxmox.ClusterResource,
+	action GuestAction,
+) error {
+	waitOpts := []tasks.WaitOption{tasks.WithPolling(PollingOptions(c)...)}
+	if !c.Bool("quiet") {
+		waitOpts = append(waitOpts, tasks.WithOutput())
+	}
+	results := RunBulk(c.Context, client, guests, c.Int("parallel"), action, c.Bool("wait"), waitOpts...)
+	if err := output.Print(c, results); err != nil {
+		return err
+	}
+	if c.Bool("wait") {
+		var started []*proxmox.Task
+		for _, result := range results {
+			if task := proxmox.NewTask(result.UPID, &client); task != nil {
+				started = append(started, task)
+			}
+		}
+		if err := SaveTaskLogs(c, started...); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, result := range results {
+		if result.Result == ResultFailed {
+			errs = append(errs, result.err)
+		}
+	}
+	if len(errs) > 0 {
+		return &bulkError{errs: errs, total: len(results)}
+	}
+	return nil
+}
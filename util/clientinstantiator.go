@@ -2,13 +2,32 @@ package util
 
 import (
 	"github.com/luthermonson/go-proxmox"
+	"github.com/urfave/cli/v2"
 )
 
-func InstantiateClient(pveUrl string, credentials proxmox.Credentials) proxmox.Client {
+func InstantiateClient(pveUrl string, opts ...proxmox.Option) proxmox.Client {
 
 	client := proxmox.NewClient(
 		pveUrl,
-		proxmox.WithCredentials(&credentials),
+		opts...,
 	)
 	return *client
 }
+
+// GetClient builds a client from the global connection, TLS, and
+// authentication flags.
+func GetClient(c *cli.Context) (proxmox.Client, error) {
+	httpClient, err := GetHttpClient(c)
+	if err != nil {
+		return proxmox.Client{}, err
+	}
+	authOption, err := GetAuthOption(c, httpClient)
+	if err != nil {
+		return proxmox.Client{}, err
+	}
+	return InstantiateClient(
+		GetPveUrl(c),
+		proxmox.WithHTTPClient(httpClient),
+		authOption,
+	), nil
+}
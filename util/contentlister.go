@@ -0,0 +1,203 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/output"
+	"github.com/urfave/cli/v2"
+)
+
+// The content types of storage volumes.
+const (
+	ContentBackup  = "backup"
+	ContentIso     = "iso"
+	ContentVztmpl  = "vztmpl"
+	ContentImages  = "images"
+	ContentRootdir = "rootdir"
+)
+
+// StorageContentTypes are the content types that `--type` accepts.
+var StorageContentTypes = []string{ContentBackup, ContentIso, ContentVztmpl, ContentImages, ContentRootdir}
+
+// Volume is a volume on a storage: a backup archive, ISO image, container
+// template or guest disk.
+type Volume struct {
+	Volid     string       `json:"volid" table:"Volume"`
+	Node      string       `json:"node"`
+	Content   string       `json:"content" table:"Type"`
+	Format    string       `json:"format"`
+	Size      output.Bytes `json:"size"`
+	CTime     time.Time    `json:"ctime" table:"Created"`
+	VMID      uint64       `json:"vmid,omitempty" table:"VMID"`
+	Notes     string       `json:"notes,omitempty"`
+	Protected bool         `json:"protected"`
+}
+
+// apiVolume is a volume as the storage content endpoint returns it.
+type apiVolume struct {
+	Volid     string `json:"volid"`
+	Content   string `json:"content"`
+	Format    string `json:"format"`
+	Size      uint64 `json:"size"`
+	CTime     int64  `json:"ctime"`
+	VMID      uint64 `json:"vmid"`
+	Notes     string `json:"notes"`
+	Protected int    `json:"protected"`
+}
+
+// ContentFilter narrows down ListContent. Empty fields match everything.
+type ContentFilter struct {
+	Content string
+	VMID    uint64
+}
+
+// ListContent returns the volumes on storage as seen from node, newest
+// first.
+func ListContent(
+	ctx context.Context,
+	client proxmox.Client,
+	node string,
+	storage string,
+	f ContentFilter,
+) ([]*Volume, error) {
+	query := url.Values{}
+	if f.Content != "" {
+		query.Set("content", f.Content)
+	}
+	if f.VMID != 0 {
+		query.Set("vmid", strconv.FormatUint(f.VMID, 10))
+	}
+	path := fmt.Sprintf("/nodes/%s/storage/%s/content", node, storage)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var list []*apiVolume
+	if err := client.Get(ctx, path, &list); err != nil {
+		return nil, WrapApiError(fmt.Errorf("storage %s on node %s: %w", storage, node, err))
+	}
+	volumes := []*Volume{}
+	for _, v := range list {
+		volume := &Volume{
+			Volid:     v.Volid,
+			Node:      node,
+			Content:   v.Content,
+			Format:    v.Format,
+			Size:      output.Bytes(v.Size),
+			VMID:      v.VMID,
+			Notes:     v.Notes,
+			Protected: v.Protected != 0,
+		}
+		if v.CTime != 0 {
+			volume.CTime = time.Unix(v.CTime, 0)
+		}
+		volumes = append(volumes, volume)
+	}
+	SortVolumes(volumes)
+	return volumes, nil
+}
+
+// SortVolumes sorts volumes newest first.
+func SortVolumes(volumes []*Volume) {
+	sort.SliceStable(volumes, func(i, j int) bool {
+		return volumes[i].CTime.After(volumes[j].CTime)
+	})
+}
+
+// StorageLocation is a storage as seen from a node.
+type StorageLocation struct {
+	Node    string
+	Storage string
+}
+
+// FindStorages returns where to list the content of the available storages
+// that pass match: shared storages once, from the first node that has them,
+// and other storages once per node. An empty name matches every storage.
+func FindStorages(
+	ctx context.Context,
+	client proxmox.Client,
+	name string,
+	match func(rs *proxmox.ClusterResource) bool,
+) ([]StorageLocation, error) {
+	resources, err := GetResourceList(ctx, client, WithStorage())
+	if err != nil {
+		return nil, err
+	}
+	var locations []StorageLocation
+	seenShared := map[string]bool{}
+	for _, rs := range resources {
+		if rs.Status != "available" || (name != "" && rs.Storage != name) {
+			continue
+		}
+		if match != nil && !match(rs) {
+			continue
+		}
+		if rs.Shared != 0 {
+			if seenShared[rs.Storage] {
+				continue
+			}
+			seenShared[rs.Storage] = true
+		}
+		locations = append(locations, StorageLocation{Node: rs.Node, Storage: rs.Storage})
+	}
+	return locations, nil
+}
+
+// HasContent reports whether the storage described by rs may hold volumes
+// of content type.
+func HasContent(rs *proxmox.ClusterResource, content string) bool {
+	return containsString(strings.Split(rs.Content, ","), content)
+}
+
+// ListContentAt lists the volumes of every location in parallel, newest
+// first. Locations that fail are left out, and their errors joined.
+func ListContentAt(
+	ctx context.Context,
+	client proxmox.Client,
+	locations []StorageLocation,
+	f ContentFilter,
+) ([]*Volume, error) {
+	results := make([][]*Volume, len(locations))
+	errs := make([]error, len(locations))
+	_ = RunParallel(ctx, len(locations), DefaultParallel, func(ctx context.Context, i int) error {
+		results[i], errs[i] = ListContent(ctx, client, locations[i].Node, locations[i].Storage, f)
+		return nil
+	})
+	volumes := []*Volume{}
+	for _, list := range results {
+		volumes = append(volumes, list...)
+	}
+	SortVolumes(volumes)
+	return volumes, errors.Join(errs...)
+}
+
+// ContentFilterFlags returns the flags read by GetContentFilter.
+func ContentFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "only list volumes of content `TYPE`: " + strings.Join(StorageContentTypes, ", "),
+		},
+		&cli.Uint64Flag{
+			Name:  "vmid",
+			Usage: "only list volumes of guest `VMID`",
+		},
+	}
+}
+
+// GetContentFilter builds a ContentFilter from the ContentFilterFlags.
+func GetContentFilter(c *cli.Context) (ContentFilter, error) {
+	f := ContentFilter{Content: c.String("type"), VMID: c.Uint64("vmid")}
+	if f.Content != "" && !containsString(StorageContentTypes, f.Content) {
+		return f, fmt.Errorf("unknown content type %q, use one of: %s",
+			f.Content, strings.Join(StorageContentTypes, ", "))
+	}
+	return f, nil
+}
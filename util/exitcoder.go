@@ -0,0 +1,72 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/tasks"
+	"github.com/urfave/cli/v2"
+)
+
+// Exit codes, so scripts can tell why gomox failed.
+const (
+	ExitSuccess    = 0
+	ExitError      = 1   // usage, configuration and other errors
+	ExitTaskFailed = 2   // a task finished unsuccessfully
+	ExitTimeout    = 3   // gave up waiting for a task
+	ExitApiError   = 4   // the Proxmox API could not be reached or returned an error
+	ExitInterrupt  = 130 // interrupted by SIGINT or SIGTERM
+)
+
+// ApiError is an error returned by, or while reaching, the Proxmox API.
+type ApiError struct {
+	Err error
+}
+
+func (e *ApiError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ApiError) Unwrap() error {
+	return e.Err
+}
+
+// WrapApiError marks err as an ApiError. It returns nil for a nil err.
+func WrapApiError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	return &ApiError{Err: err}
+}
+
+// ExitCode returns the exit code for an error returned by a command.
+func ExitCode(err error) int {
+	var (
+		exitCoder cli.ExitCoder
+		apiErr    *ApiError
+		urlErr    *url.Error
+	)
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &exitCoder):
+		return exitCoder.ExitCode()
+	case errors.Is(err, tasks.ErrTaskFailed):
+		return ExitTaskFailed
+	case errors.Is(err, tasks.ErrTaskTimeout), proxmox.IsTimeout(err),
+		errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupt
+	case errors.As(err, &apiErr), errors.As(err, &urlErr),
+		proxmox.IsNotAuthorized(err), proxmox.IsNotFound(err):
+		return ExitApiError
+	}
+	return ExitError
+}
@@ -0,0 +1,253 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	TagSelectorPrefix  = "tag:"
+	PoolSelectorPrefix = "pool:"
+)
+
+// GuestArgUsage describes the guest references accepted by ResolveGuests.
+const GuestArgUsage = "GUEST is a VMID, a name, name@node, or a tag:TAG or pool:POOL selector."
+
+// GuestsArgUsage describes the guest references accepted by ResolveGuestArgs.
+const GuestsArgUsage = "Each GUEST is a VMID, a VMID range like 100-120, a name, name@node, " +
+	"or a tag:TAG or pool:POOL selector."
+
+// NoGuestError is returned when a guest reference matches no guest.
+type NoGuestError struct {
+	Ref string
+}
+
+func (e *NoGuestError) Error() string {
+	return fmt.Sprintf("no guest matches %q", e.Ref)
+}
+
+// IsGuestSelector reports whether ref may refer to several guests: a VMID
+// range or a tag: or pool: selector.
+func IsGuestSelector(ref string) bool {
+	if _, _, ok := parseVmidRange(ref); ok {
+		return true
+	}
+	return strings.HasPrefix(ref, TagSelectorPrefix) || strings.HasPrefix(ref, PoolSelectorPrefix)
+}
+
+// parseVmidRange parses a VMID range like `100-120`.
+func parseVmidRange(ref string) (first uint64, last uint64, ok bool) {
+	firstText, lastText, found := strings.Cut(ref, "-")
+	if !found {
+		return 0, 0, false
+	}
+	first, err := strconv.ParseUint(firstText, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	last, err = strconv.ParseUint(lastText, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return first, last, true
+}
+
+// MatchGuests returns the guests in resources that ref refers to. ref is a
+// VMID, a VMID range like `100-120`, an exact name, `name@node`, `tag:TAG`,
+// or `pool:POOL`.
+func MatchGuests(resources []*proxmox.ClusterResource, ref string) ([]*proxmox.ClusterResource, error) {
+	var match func(rs *proxmox.ClusterResource) bool
+	switch {
+	case strings.HasPrefix(ref, TagSelectorPrefix):
+		tag := strings.TrimPrefix(ref, TagSelectorPrefix)
+		if tag == "" {
+			return nil, fmt.Errorf("please supply a tag: %sTAG", TagSelectorPrefix)
+		}
+		match = func(rs *proxmox.ClusterResource) bool { return containsString(SplitTags(rs.Tags), tag) }
+	case strings.HasPrefix(ref, PoolSelectorPrefix):
+		pool := strings.TrimPrefix(ref, PoolSelectorPrefix)
+		if pool == "" {
+			return nil, fmt.Errorf("please supply a pool: %sPOOL", PoolSelectorPrefix)
+		}
+		match = func(rs *proxmox.ClusterResource) bool { return rs.Pool == pool }
+	default:
+		if first, last, ok := parseVmidRange(ref); ok {
+			if err := CheckVmidRange(first); err != nil {
+				return nil, err
+			}
+			if err := CheckVmidRange(last); err != nil {
+				return nil, err
+			}
+			if first > last {
+				return nil, fmt.Errorf("invalid VMID range %s", ref)
+			}
+			match = func(rs *proxmox.ClusterResource) bool { return rs.VMID >= first && rs.VMID <= last }
+			break
+		}
+		if vmid, err := strconv.ParseUint(ref, 10, 64); err == nil {
+			if err := CheckVmidRange(vmid); err != nil {
+				return nil, err
+			}
+			match = func(rs *proxmox.ClusterResource) bool { return rs.VMID == vmid }
+			break
+		}
+		name, node, hasNode := strings.Cut(ref, "@")
+		if name == "" {
+			return nil, fmt.Errorf("please supply a guest. %s", GuestArgUsage)
+		}
+		match = func(rs *proxmox.ClusterResource) bool {
+			return rs.Name == name && (!hasNode || rs.Node == node)
+		}
+	}
+
+	var matches []*proxmox.ClusterResource
+	for _, rs := range resources {
+		if match(rs) {
+			matches = append(matches, rs)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, &NoGuestError{Ref: ref}
+	}
+	return matches, nil
+}
+
+func ambiguousGuestError(ref string, matches []*proxmox.ClusterResource) error {
+	candidates := make([]string, len(matches))
+	for i, rs := range matches {
+		candidates[i] = fmt.Sprintf("%d (%s@%s, %s)", rs.VMID, rs.Name, rs.Node, rs.Type)
+	}
+	return fmt.Errorf(
+		"%q is ambiguous, it matches %s; use a VMID or name@node",
+		ref, strings.Join(candidates, ", "),
+	)
+}
+
+// ResolveGuests returns the guests ref refers to (see MatchGuests).
+func ResolveGuests(ctx context.Context, client proxmox.Client, ref string) ([]*proxmox.ClusterResource, error) {
+	resources, err := GetGuestList(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return MatchGuests(resources, ref)
+}
+
+// ResolveGuest returns the single guest ref refers to, or an error listing
+// the candidates if there are several.
+func ResolveGuest(ctx context.Context, client proxmox.Client, ref string) (*proxmox.ClusterResource, error) {
+	resources, err := GetGuestList(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return MatchGuest(resources, ref)
+}
+
+// MatchGuest is ResolveGuest on the guests in resources.
+func MatchGuest(resources []*proxmox.ClusterResource, ref string) (*proxmox.ClusterResource, error) {
+	matches, err := MatchGuests(resources, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) > 1 {
+		return nil, ambiguousGuestError(ref, matches)
+	}
+	return matches[0], nil
+}
+
+// ResolveGuestArgs resolves every guest reference in args against a single
+// cluster/resources listing (see MatchGuestArgs).
+func ResolveGuestArgs(ctx context.Context, client proxmox.Client, args []string, ignoreMissing bool) (
+	[]*proxmox.ClusterResource,
+	error,
+) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("please supply one or more guests. %s", GuestsArgUsage)
+	}
+	resources, err := GetGuestList(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return MatchGuestArgs(resources, args, ignoreMissing)
+}
+
+// MatchGuestArgs resolves every guest reference in args against the guests
+// in resources, dropping duplicates. Names must match exactly one guest,
+// while ranges and selectors may match several. With ignoreMissing,
+// references that match nothing are skipped with a warning.
+func MatchGuestArgs(resources []*proxmox.ClusterResource, args []string, ignoreMissing bool) (
+	[]*proxmox.ClusterResource,
+	error,
+) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("please supply one or more guests. %s", GuestsArgUsage)
+	}
+	var guests []*proxmox.ClusterResource
+	seen := map[uint64]bool{}
+	for _, arg := range args {
+		matches, err := MatchGuests(resources, arg)
+		var noGuest *NoGuestError
+		if ignoreMissing && errors.As(err, &noGuest) {
+			logrus.Warnf("%s\n", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) > 1 && !IsGuestSelector(arg) {
+			return nil, ambiguousGuestError(arg, matches)
+		}
+		for _, rs := range matches {
+			if !seen[rs.VMID] {
+				seen[rs.VMID] = true
+				guests = append(guests, rs)
+			}
+		}
+	}
+	return guests, nil
+}
+
+// GuestPath returns the API path of the guest described by rs, for QEMU VMs
+// and LXC containers alike, e.g. /nodes/pve1/lxc/200.
+func GuestPath(rs *proxmox.ClusterResource) string {
+	return fmt.Sprintf("/nodes/%s/%s/%d", rs.Node, rs.Type, rs.VMID)
+}
+
+// GetVirtualMachine fetches the QEMU VM described by rs.
+func GetVirtualMachine(ctx context.Context, client proxmox.Client, rs *proxmox.ClusterResource) (
+	*proxmox.VirtualMachine,
+	error,
+) {
+	if rs.Type != QemuResource {
+		return nil, fmt.Errorf("guest %d (%s) is an %s container, not a QEMU VM", rs.VMID, rs.Name, rs.Type)
+	}
+	node, err := client.Node(ctx, rs.Node)
+	if err != nil {
+		return nil, WrapApiError(err)
+	}
+	vm, err := node.VirtualMachine(ctx, int(rs.VMID))
+	if err != nil {
+		return nil, WrapApiError(err)
+	}
+	return vm, nil
+}
+
+// GetVirtualMachineArg resolves the first argument to a QEMU VM.
+func GetVirtualMachineArg(ctx context.Context, client proxmox.Client, args []string) (
+	*proxmox.VirtualMachine,
+	error,
+) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("please supply a guest. %s", GuestArgUsage)
+	}
+	rs, err := ResolveGuest(ctx, client, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return GetVirtualMachine(ctx, client, rs)
+}
@@ -0,0 +1,202 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+)
+
+// testGuests is a cluster with hyphenated names, two guests named dup, and a
+// guest named like a VMID range.
+var testGuests = []*proxmox.ClusterResource{
+	{VMID: 100, Name: "web-1", Node: "pve1", Type: QemuResource, Tags: "web;prod", Pool: "prod"},
+	{VMID: 101, Name: "web-2", Node: "pve2", Type: QemuResource, Tags: "web", Pool: "prod"},
+	{VMID: 102, Name: "db", Node: "pve2", Type: QemuResource, Tags: "prod"},
+	{VMID: 103, Name: "dup", Node: "pve2", Type: QemuResource},
+	{VMID: 200, Name: "ct1", Node: "pve1", Type: LxcResource, Tags: "web"},
+	{VMID: 201, Name: "dup", Node: "pve1", Type: LxcResource},
+	{VMID: 300, Name: "100-101", Node: "pve1", Type: QemuResource},
+}
+
+func vmids(list []*proxmox.ClusterResource) []uint64 {
+	ids := []uint64{}
+	for _, rs := range list {
+		ids = append(ids, rs.VMID)
+	}
+	return ids
+}
+
+func TestParseVmidRange(t *testing.T) {
+	tests := []struct {
+		ref         string
+		first, last uint64
+		ok          bool
+	}{
+		{"100-120", 100, 120, true},
+		{"120-100", 120, 100, true}, // rejected by MatchGuests
+		{"100", 0, 0, false},
+		{"web-1", 0, 0, false},
+		{"100-web", 0, 0, false},
+		{"-100", 0, 0, false},
+		{"100-", 0, 0, false},
+		{"100-120-130", 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, ok := parseVmidRange(tt.ref)
+		if first != tt.first || last != tt.last || ok != tt.ok {
+			t.Errorf("parseVmidRange(%q) = %d, %d, %v, want %d, %d, %v",
+				tt.ref, first, last, ok, tt.first, tt.last, tt.ok)
+		}
+	}
+}
+
+func TestIsGuestSelector(t *testing.T) {
+	for ref, want := range map[string]bool{
+		"100":      false,
+		"web-1":    false,
+		"dup@pve1": false,
+		"100-120":  true,
+		"tag:web":  true,
+		"pool:p":   true,
+	} {
+		if got := IsGuestSelector(ref); got != want {
+			t.Errorf("IsGuestSelector(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func TestMatchGuests(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    []uint64
+		wantErr string // a substring of the error
+	}{
+		{ref: "100", want: []uint64{100}},
+		{ref: "201", want: []uint64{201}},
+		{ref: "99", wantErr: "between 100 and 999999999"},
+		{ref: "1000000000", wantErr: "between 100 and 999999999"},
+		{ref: "100-102", want: []uint64{100, 101, 102}},
+		{ref: "100-100", want: []uint64{100}},
+		{ref: "102-100", wantErr: "invalid VMID range"},
+		{ref: "50-100", wantErr: "between 100 and 999999999"},
+		{ref: "500-600", wantErr: `no guest matches "500-600"`},
+		{ref: "web-1", want: []uint64{100}},
+		{ref: "web-2", want: []uint64{101}},
+		{ref: "dup", want: []uint64{103, 201}},
+		{ref: "dup@pve1", want: []uint64{201}},
+		{ref: "dup@pve2", want: []uint64{103}},
+		{ref: "dup@pve3", wantErr: `no guest matches "dup@pve3"`},
+		{ref: "@pve1", wantErr: "please supply a guest"},
+		{ref: "nope", wantErr: `no guest matches "nope"`},
+		{ref: "tag:web", want: []uint64{100, 101, 200}},
+		{ref: "tag:prod", want: []uint64{100, 102}},
+		{ref: "tag:we", wantErr: "no guest matches"},
+		{ref: "tag:", wantErr: "please supply a tag"},
+		{ref: "pool:prod", want: []uint64{100, 101}},
+		{ref: "pool:", wantErr: "please supply a pool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			matches, err := MatchGuests(testGuests, tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MatchGuests(%q) error = %v, want %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MatchGuests(%q) error = %v", tt.ref, err)
+			}
+			if got := vmids(matches); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchGuests(%q) = %v, want %v", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchGuestsNoGuestError(t *testing.T) {
+	_, err := MatchGuests(testGuests, "nope")
+	var noGuest *NoGuestError
+	if !errors.As(err, &noGuest) || noGuest.Ref != "nope" {
+		t.Errorf("MatchGuests(nope) error = %#v, want a NoGuestError", err)
+	}
+}
+
+func TestMatchGuest(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    uint64
+		wantErr string
+	}{
+		{ref: "100", want: 100},
+		{ref: "web-1", want: 100},
+		{ref: "dup@pve1", want: 201},
+		{ref: "dup", wantErr: `"dup" is ambiguous, it matches 103 (dup@pve2, qemu), 201 (dup@pve1, lxc)`},
+		// single-guest commands take selectors only if they match one guest
+		{ref: "100-102", wantErr: `"100-102" is ambiguous`},
+		{ref: "tag:web", wantErr: `"tag:web" is ambiguous`},
+		{ref: "pool:prod", wantErr: `"pool:prod" is ambiguous`},
+		{ref: "102-102", want: 102},
+		{ref: "tag:web;prod", wantErr: "no guest matches"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			rs, err := MatchGuest(testGuests, tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MatchGuest(%q) error = %v, want %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MatchGuest(%q) error = %v", tt.ref, err)
+			}
+			if rs.VMID != tt.want {
+				t.Errorf("MatchGuest(%q) = %d, want %d", tt.ref, rs.VMID, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchGuestArgs(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		ignoreMissing bool
+		want          []uint64
+		wantErr       string
+	}{
+		{name: "vmids", args: []string{"101", "100"}, want: []uint64{101, 100}},
+		{name: "duplicates dropped", args: []string{"100", "100-101", "web-1"}, want: []uint64{100, 101}},
+		{name: "range and selectors", args: []string{"100-101", "tag:web", "pool:prod"}, want: []uint64{100, 101, 200}},
+		{name: "hyphenated name", args: []string{"web-2"}, want: []uint64{101}},
+		{name: "a range, not the guest named like one", args: []string{"100-101"}, want: []uint64{100, 101}},
+		{name: "ambiguous name", args: []string{"100", "dup"}, wantErr: `"dup" is ambiguous`},
+		{name: "name@node", args: []string{"dup@pve1", "dup@pve2"}, want: []uint64{201, 103}},
+		{name: "missing", args: []string{"100", "nope"}, wantErr: `no guest matches "nope"`},
+		{name: "missing ignored", args: []string{"100", "nope", "999"}, ignoreMissing: true, want: []uint64{100}},
+		{name: "out of range not ignored", args: []string{"100", "99"}, ignoreMissing: true, wantErr: "between 100"},
+		{name: "reversed range", args: []string{"101-100"}, wantErr: "invalid VMID range 101-100"},
+		{name: "no args", args: nil, wantErr: "please supply one or more guests"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guests, err := MatchGuestArgs(testGuests, tt.args, tt.ignoreMissing)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MatchGuestArgs(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MatchGuestArgs(%q) error = %v", tt.args, err)
+			}
+			if got := vmids(guests); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchGuestArgs(%q) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultParallel is the number of concurrent API requests used when none is given.
+const DefaultParallel = 8
+
+/*
+RunParallel calls fn(ctx, i) for i in [0, n) on at most workers goroutines.
+
+The first error cancels the context passed to the remaining calls, no new
+calls are started, and that error is returned once all running calls have
+returned. Callers that want every call to run should record failures
+themselves and return nil.
+*/
+func RunParallel(ctx context.Context, n int, workers int, fn func(ctx context.Context, i int) error) error {
+	if workers <= 0 {
+		workers = DefaultParallel
+	}
+	if workers > n {
+		workers = n
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	indexes := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
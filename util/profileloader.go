@@ -0,0 +1,207 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ConfigDirName  = "gomox"
+	ConfigFileName = "config.yaml"
+	profileKey     = "profile" // cli.App.Metadata key for the active profile
+	profileNameKey = "profileName"
+
+	// ProfileCommandName is the name of the command that manages profiles.
+	ProfileCommandName = "profile"
+)
+
+// Profile is a named set of connection settings.
+type Profile struct {
+	Url         string `yaml:"url,omitempty" json:"url" table:"URL"`
+	User        string `yaml:"user,omitempty" json:"user"`
+	Realm       string `yaml:"realm,omitempty" json:"realm"`
+	TokenId     string `yaml:"token-id,omitempty" json:"token-id" table:"Token ID"`
+	TokenSecret string `yaml:"token-secret,omitempty" json:"token-secret" table:"Token Secret"`
+	Insecure    bool   `yaml:"insecure,omitempty" json:"insecure"`
+	CaFile      string `yaml:"ca-file,omitempty" json:"ca-file" table:"CA File"`
+	Fingerprint string `yaml:"fingerprint,omitempty" json:"fingerprint"`
+	Node        string `yaml:"node,omitempty" json:"node"` // default node
+	Pool        string `yaml:"pool,omitempty" json:"pool"` // default pool
+}
+
+// Config is the gomox configuration file.
+type Config struct {
+	Current  string              `yaml:"current,omitempty"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// DefaultConfigPath returns ~/.config/gomox/config.yaml (or the platform equivalent).
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ConfigFileName
+	}
+	return filepath.Join(dir, ConfigDirName, ConfigFileName)
+}
+
+// GetConfigPath returns the config file path from the `config` flag,
+// falling back to DefaultConfigPath.
+func GetConfigPath(c *cli.Context) string {
+	if path := c.String("config"); path != "" {
+		return path
+	}
+	return DefaultConfigPath()
+}
+
+// LoadConfig reads the config file at path. A missing file is an empty config.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{Profiles: map[string]*Profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	return cfg, nil
+}
+
+// Save writes the config to path. The file may hold token secrets, so it is
+// only readable by the owner.
+func (cfg *Config) Save(path string) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// ProfileNames returns the names of all profiles, sorted.
+func (cfg *Config) ProfileNames() []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProfile returns the named profile.
+func (cfg *Config) GetProfile(name string) (*Profile, error) {
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile named %q (see `gomox profile list`)", name)
+	}
+	return profile, nil
+}
+
+// ApplyProfile loads the profile selected by `--profile` (or the current
+// profile in the config file) and uses its values for every connection flag
+// that was not given explicitly on the command line or in the environment.
+//
+// A missing profile only warns for the profile command, so that a stale
+// current profile or GOMOX_PROFILE can still be fixed with it.
+func ApplyProfile(c *cli.Context) error {
+	cfg, err := LoadConfig(GetConfigPath(c))
+	if err != nil {
+		return err
+	}
+	name := c.String("profile")
+	if name == "" {
+		name = cfg.Current
+	}
+	if name == "" {
+		return nil
+	}
+	profile, err := cfg.GetProfile(name)
+	if err != nil && c.Args().First() == ProfileCommandName {
+		logrus.Warnf("%s\n", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("using profile %s", name)
+	c.App.Metadata[profileNameKey] = name
+	c.App.Metadata[profileKey] = profile
+
+	// an explicit host/port/scheme overrides the profile's URL
+	if !c.IsSet("pveurl") && !c.IsSet("pvehost") && !c.IsSet("pveport") && !c.IsSet("scheme") {
+		if err := setUnlessSet(c, "pveurl", profile.Url); err != nil {
+			return err
+		}
+	}
+	// token and password auth are mutually exclusive, so a password from the
+	// environment also overrides the profile's token
+	if !c.IsSet("pvepassword") {
+		if err := setUnlessSet(c, "token-id", profile.TokenId); err != nil {
+			return err
+		}
+		if err := setUnlessSet(c, "token-secret", profile.TokenSecret); err != nil {
+			return err
+		}
+	}
+	for flag, value := range map[string]string{
+		"pveuser":     profile.User,
+		"pverealm":    profile.Realm,
+		"ca-file":     profile.CaFile,
+		"fingerprint": profile.Fingerprint,
+	} {
+		if err := setUnlessSet(c, flag, value); err != nil {
+			return err
+		}
+	}
+	if profile.Insecure {
+		if err := setUnlessSet(c, "insecure", "true"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setUnlessSet(c *cli.Context, flag string, value string) error {
+	if value == "" || c.IsSet(flag) {
+		return nil
+	}
+	return c.Set(flag, value)
+}
+
+// GetActiveProfile returns the profile applied by ApplyProfile, or nil.
+func GetActiveProfile(c *cli.Context) (name string, profile *Profile) {
+	profile, _ = c.App.Metadata[profileKey].(*Profile)
+	name, _ = c.App.Metadata[profileNameKey].(string)
+	return name, profile
+}
+
+// GetDefaultNode returns the active profile's default node, if any.
+func GetDefaultNode(c *cli.Context) string {
+	if _, profile := GetActiveProfile(c); profile != nil {
+		return profile.Node
+	}
+	return ""
+}
+
+// GetDefaultPool returns the active profile's default pool, if any.
+func GetDefaultPool(c *cli.Context) string {
+	if _, profile := GetActiveProfile(c); profile != nil {
+		return profile.Pool
+	}
+	return ""
+}
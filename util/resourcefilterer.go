@@ -0,0 +1,130 @@
+package util
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/urfave/cli/v2"
+)
+
+// NamePattern matches guest names. A nil NamePattern matches every name.
+type NamePattern func(name string) bool
+
+// ParseNamePattern parses a glob like `web*`, or a regular expression
+// between slashes like `/^web[0-9]+$/`.
+func ParseNamePattern(pattern string) (NamePattern, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern %s: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid name pattern %s: %w", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+// ResourceFilter selects cluster resources by their cluster/resources
+// fields, so no per-resource requests are needed. Empty fields match
+// everything; a resource must match one of the Nodes, Pools and Statuses,
+// and carry all of the Tags.
+type ResourceFilter struct {
+	Nodes    []string
+	Pools    []string
+	Statuses []string
+	Tags     []string
+	Name     NamePattern
+}
+
+// Matches reports whether rs passes the filter.
+func (f *ResourceFilter) Matches(rs *proxmox.ClusterResource) bool {
+	if len(f.Nodes) > 0 && !containsString(f.Nodes, rs.Node) {
+		return false
+	}
+	if len(f.Pools) > 0 && !containsString(f.Pools, rs.Pool) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !containsString(f.Statuses, rs.Status) {
+		return false
+	}
+	tags := SplitTags(rs.Tags)
+	for _, tag := range f.Tags {
+		if !containsString(tags, tag) {
+			return false
+		}
+	}
+	if f.Name != nil && !f.Name(rs.Name) {
+		return false
+	}
+	return true
+}
+
+// SplitTags splits a PVE tag list, which may be separated by semicolons,
+// commas or spaces.
+func SplitTags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	})
+}
+
+// WithFilter further filters GetResourceList with a ResourceFilter.
+func WithFilter(f *ResourceFilter) GetResourceListOption {
+	return func(c *getResourceListConfig) { c.matchers = append(c.matchers, f.Matches) }
+}
+
+// ResourceFilterFlags are the flags read by GetResourceFilter.
+func ResourceFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "node",
+			Usage:    "only guests on `NODE` (repeatable)",
+			Category: "filters",
+		},
+		&cli.StringSliceFlag{
+			Name:     "pool",
+			Usage:    "only guests in `POOL` (repeatable)",
+			Category: "filters",
+		},
+		&cli.StringSliceFlag{
+			Name:     "status",
+			Usage:    "only guests with `STATUS`, e.g. running or stopped (repeatable)",
+			Category: "filters",
+		},
+		&cli.StringSliceFlag{
+			Name:     "tag",
+			Usage:    "only guests tagged `TAG` (repeatable, all must match)",
+			Category: "filters",
+		},
+		&cli.StringFlag{
+			Name:     "name",
+			Usage:    "only guests whose name matches `PATTERN`, a glob or a /regex/",
+			Category: "filters",
+		},
+	}
+}
+
+// GetResourceFilter builds a ResourceFilter from the ResourceFilterFlags.
+func GetResourceFilter(c *cli.Context) (*ResourceFilter, error) {
+	name, err := ParseNamePattern(c.String("name"))
+	if err != nil {
+		return nil, err
+	}
+	return &ResourceFilter{
+		Nodes:    c.StringSlice("node"),
+		Pools:    c.StringSlice("pool"),
+		Statuses: c.StringSlice("status"),
+		Tags:     c.StringSlice("tag"),
+		Name:     name,
+	}, nil
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/luthermonson/go-proxmox"
 )
@@ -42,23 +43,23 @@ func GetVirtualMachineByVMID(ctx context.Context, vmid uint64, client proxmox.Cl
 
 	cluster, err := client.Cluster(ctx)
 	if err != nil {
-		return nil, err
+		return nil, WrapApiError(err)
 	}
 
 	resources, err := cluster.Resources(ctx, VmFilter)
 	if err != nil {
-		return nil, err
+		return nil, WrapApiError(err)
 	}
 
 	for _, rs := range resources {
 		if rs.VMID == vmid {
 			node, err = client.Node(ctx, rs.Node)
 			if err != nil {
-				return nil, err
+				return nil, WrapApiError(err)
 			}
 			vm, err = node.VirtualMachine(ctx, int(rs.VMID))
 			if err != nil {
-				return nil, err
+				return nil, WrapApiError(err)
 			}
 		}
 	}
@@ -74,6 +75,7 @@ func GetVirtualMachineByVMID(ctx context.Context, vmid uint64, client proxmox.Cl
 type getResourceListConfig struct {
 	filter        string
 	furtherFilter []string
+	matchers      []func(rs *proxmox.ClusterResource) bool
 }
 
 // GetResourceListOption specifies the type of Resources for GetResource to get.
@@ -111,7 +113,7 @@ func WithQemu() GetResourceListOption {
 	return func(c *getResourceListConfig) { c.furtherFilter = append(c.furtherFilter, QemuResource) }
 }
 
-// WithLxc further filters GetResourceList for Qemu VMs.
+// WithLxc further filters GetResourceList for LXC containers.
 func WithLxc() GetResourceListOption {
 	return func(c *getResourceListConfig) { c.furtherFilter = append(c.furtherFilter, LxcResource) }
 }
@@ -138,16 +140,19 @@ func GetResourceList(
 	}
 	cluster, err := client.Cluster(ctx)
 	if err != nil {
-		return nil, err
+		return nil, WrapApiError(err)
 	}
 
 	resources, err := cluster.Resources(ctx, c.filter)
 	if err != nil {
-		return nil, err
+		return nil, WrapApiError(err)
 	}
 	for _, rs := range resources {
-		if err != nil {
-			return nil, err
+		if c.furtherFilter != nil && !containsString(c.furtherFilter, rs.Type) {
+			continue
+		}
+		if !matchesAll(c.matchers, rs) {
+			continue
 		}
 		rsList = append(rsList, rs)
 	}
@@ -155,34 +160,150 @@ func GetResourceList(
 	return rsList, nil
 }
 
-func GetVirtualMachineList(
+func matchesAll(matchers []func(rs *proxmox.ClusterResource) bool, rs *proxmox.ClusterResource) bool {
+	for _, matches := range matchers {
+		if !matches(rs) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGuestList returns the QEMU VMs and LXC containers in the cluster from a
+// single cluster/resources request. WithQemu or WithLxc limits it to one kind,
+// and WithFilter selects guests. Use GetGuestDetails for what cluster/resources
+// does not report.
+func GetGuestList(
 	ctx context.Context,
 	client proxmox.Client,
-	furtherFilterList ...string,
-) (vmList []proxmox.VirtualMachine, err error) {
-	var node *proxmox.Node
-	var vm *proxmox.VirtualMachine
+	opts ...GetResourceListOption,
+) ([]*proxmox.ClusterResource, error) {
+	return GetResourceList(ctx, client, append([]GetResourceListOption{WithVm()}, opts...)...)
+}
 
-	resources, err := GetResourceList(ctx, client, WithVm())
+// GetGuestDetails fetches the current status of each guest in resources,
+// filling QemuResources and LxcResources in the same order. At most parallel
+// requests run at once, and the first failure cancels the rest.
+func GetGuestDetails(
+	ctx context.Context,
+	client proxmox.Client,
+	resources []*proxmox.ClusterResource,
+	parallel int,
+) (*ResourceList, error) {
+	var nodeNames []string
+	nodes := map[string]*proxmox.Node{}
 	for _, rs := range resources {
-		if furtherFilterList != nil {
-			for _, furtherFilter := range furtherFilterList {
-				if rs.Type == furtherFilter {
-					node, err = client.Node(ctx, rs.Node)
-					if err != nil {
-						return nil, err
-					}
-					vm, err = node.VirtualMachine(ctx, int(rs.VMID))
-					if err != nil {
-						return nil, err
-					}
-					vmList = append(vmList, *vm)
-				}
-			}
-		} else {
-			vmList = append(vmList, *vm)
+		if _, ok := nodes[rs.Node]; !ok {
+			nodes[rs.Node] = nil
+			nodeNames = append(nodeNames, rs.Node)
 		}
 	}
+	var mu sync.Mutex
+	err := RunParallel(ctx, len(nodeNames), parallel, func(ctx context.Context, i int) error {
+		node, err := client.Node(ctx, nodeNames[i])
+		if err != nil {
+			return WrapApiError(fmt.Errorf("node %s: %w", nodeNames[i], err))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		nodes[nodeNames[i]] = node
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	return vmList, nil
+	vms := make([]*proxmox.VirtualMachine, len(resources))
+	cts := make([]*proxmox.Container, len(resources))
+	err = RunParallel(ctx, len(resources), parallel, func(ctx context.Context, i int) (err error) {
+		rs := resources[i]
+		switch rs.Type {
+		case QemuResource:
+			vms[i], err = nodes[rs.Node].VirtualMachine(ctx, int(rs.VMID))
+		case LxcResource:
+			cts[i], err = nodes[rs.Node].Container(ctx, int(rs.VMID))
+		}
+		if err != nil {
+			return WrapApiError(fmt.Errorf("guest %d: %w", rs.VMID, err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ResourceList{}
+	for i := range resources {
+		if vms[i] != nil {
+			list.QemuResources = append(list.QemuResources, vms[i])
+		}
+		if cts[i] != nil {
+			list.LxcResources = append(list.LxcResources, cts[i])
+		}
+	}
+	return list, nil
+}
+
+// GetVirtualMachineList returns the QEMU VMs selected by opts with their
+// current status, fetching at most parallel of them at once.
+func GetVirtualMachineList(
+	ctx context.Context,
+	client proxmox.Client,
+	parallel int,
+	opts ...GetResourceListOption,
+) ([]*proxmox.VirtualMachine, error) {
+	resources, err := GetGuestList(ctx, client, append(opts, WithQemu())...)
+	if err != nil {
+		return nil, err
+	}
+	list, err := GetGuestDetails(ctx, client, resources, parallel)
+	if err != nil {
+		return nil, err
+	}
+	return list.QemuResources, nil
+}
+
+// GetGuestPIDs fetches the current status of each QEMU VM and LXC container
+// in resources and returns their PIDs by VMID, 0 for guests that aren't
+// running. At most parallel requests run at once, and the first failure
+// cancels the rest.
+func GetGuestPIDs(
+	ctx context.Context,
+	client proxmox.Client,
+	resources []*proxmox.ClusterResource,
+	parallel int,
+) (map[uint64]uint64, error) {
+	pids := make([]uint64, len(resources))
+	err := RunParallel(ctx, len(resources), parallel, func(ctx context.Context, i int) error {
+		rs := resources[i]
+		if rs.Type != QemuResource && rs.Type != LxcResource {
+			return nil
+		}
+		// proxmox.Container has no PID, so read it from the status directly
+		var status struct {
+			PID proxmox.StringOrUint64 `json:"pid"`
+		}
+		if err := client.Get(ctx, GuestPath(rs)+"/status/current", &status); err != nil {
+			return WrapApiError(fmt.Errorf("guest %d: %w", rs.VMID, err))
+		}
+		pids[i] = uint64(status.PID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	byVmid := map[uint64]uint64{}
+	for i, rs := range resources {
+		byVmid[rs.VMID] = pids[i]
+	}
+	return byVmid, nil
 }
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+)
+
+// archiveTypes tells QEMU from LXC archives by their volume name: vzdump
+// names them vzdump-qemu-*.vma* and vzdump-lxc-*.tar*, and Proxmox Backup
+// Server groups them as vm/VMID and ct/VMID.
+var archiveTypes = []struct {
+	pattern   *regexp.Regexp
+	guestType string
+}{
+	{regexp.MustCompile(`(^|[/:])vzdump-qemu-`), QemuResource},
+	{regexp.MustCompile(`(^|[/:])vzdump-(lxc|openvz)-`), LxcResource},
+	{regexp.MustCompile(`:backup/vm/\d+/`), QemuResource},
+	{regexp.MustCompile(`:backup/ct/\d+/`), LxcResource},
+	{regexp.MustCompile(`\.vma(\.\w+)?$`), QemuResource},
+	{regexp.MustCompile(`\.tar(\.\w+)?$`), LxcResource},
+}
+
+// ArchiveGuestType returns whether the backup archive volid holds a QEMU VM
+// or an LXC container, as QemuResource or LxcResource.
+func ArchiveGuestType(volid string) (string, error) {
+	for _, t := range archiveTypes {
+		if t.pattern.MatchString(volid) {
+			return t.guestType, nil
+		}
+	}
+	return "", fmt.Errorf("can't tell whether %s is a VM or container backup", volid)
+}
+
+// ArchiveStorage returns the storage part of volid, e.g. nfs for
+// nfs:backup/vzdump-qemu-100-2026_10_01-01_00_00.vma.zst.
+func ArchiveStorage(volid string) (string, error) {
+	storage, _, found := strings.Cut(volid, ":")
+	if !found || storage == "" {
+		return "", fmt.Errorf("%s is not a volume ID like STORAGE:backup/ARCHIVE", volid)
+	}
+	return storage, nil
+}
+
+// FindArchiveNode returns a node that can read the storage of volid. A
+// storage that isn't shared must only exist on one node.
+func FindArchiveNode(ctx context.Context, client proxmox.Client, volid string) (string, error) {
+	storage, err := ArchiveStorage(volid)
+	if err != nil {
+		return "", err
+	}
+	locations, err := FindStorages(ctx, client, storage, nil)
+	if err != nil {
+		return "", err
+	}
+	switch len(locations) {
+	case 0:
+		return "", fmt.Errorf("storage %s is not available on any node", storage)
+	case 1:
+		return locations[0].Node, nil
+	}
+	nodes := make([]string, len(locations))
+	for i, l := range locations {
+		nodes[i] = l.Node
+	}
+	return "", fmt.Errorf("storage %s exists on nodes %s, use --node to pick one",
+		storage, strings.Join(nodes, ", "))
+}
+
+// RestoreOptions are the settings of a restore. Empty fields use the
+// storages and settings saved in the archive.
+type RestoreOptions struct {
+	VMID    uint64
+	Storage string
+	Unique  bool // regenerate MAC addresses and other unique properties
+	Start   bool // start the guest once it is restored
+}
+
+// StartRestore restores the backup archive volid on node as guest
+// opts.VMID, through the create endpoint of guestType, and returns the
+// restore task.
+func StartRestore(
+	ctx context.Context,
+	client *proxmox.Client,
+	node string,
+	guestType string,
+	volid string,
+	opts RestoreOptions,
+) (*proxmox.Task, error) {
+	params := map[string]string{"vmid": strconv.FormatUint(opts.VMID, 10)}
+	switch guestType {
+	case QemuResource:
+		params["archive"] = volid
+	case LxcResource:
+		params["ostemplate"] = volid
+		params["restore"] = "1"
+	default:
+		return nil, fmt.Errorf("can't restore a guest of type %s", guestType)
+	}
+	if opts.Storage != "" {
+		params["storage"] = opts.Storage
+	}
+	if opts.Unique {
+		params["unique"] = "1"
+	}
+	if opts.Start {
+		params["start"] = "1"
+	}
+
+	var upid proxmox.UPID
+	if err := client.Post(ctx, fmt.Sprintf("/nodes/%s/%s", node, guestType), params, &upid); err != nil {
+		return nil, WrapApiError(err)
+	}
+	task := proxmox.NewTask(upid, client)
+	if task == nil {
+		return nil, fmt.Errorf("no restore task was started on node %s", node)
+	}
+	return task, nil
+}
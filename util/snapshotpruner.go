@@ -0,0 +1,141 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+/*
+PrunePolicy selects the snapshots to keep, like the keep-* options of
+Proxmox Backup Server. KeepLast keeps the newest snapshots; each of the other
+rules then keeps the newest snapshot of as many hours, days or weeks, skipping
+those periods that already have a kept snapshot. Everything else is pruned.
+
+Only snapshots whose name starts with Prefix are considered, never the
+CurrentSnapshot. A policy without keep rules keeps everything.
+*/
+type PrunePolicy struct {
+	KeepLast   int
+	KeepHourly int
+	KeepDaily  int
+	KeepWeekly int
+	Prefix     string
+}
+
+// IsEmpty reports whether the policy has no keep rules, so Select keeps
+// every snapshot.
+func (p *PrunePolicy) IsEmpty() bool {
+	return p.KeepLast <= 0 && p.KeepHourly <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0
+}
+
+// Select splits the snapshots that the policy considers into those to keep
+// and those to prune, both newest first.
+func (p *PrunePolicy) Select(snapshots []*Snapshot) (keep []*Snapshot, prune []*Snapshot) {
+	var list []*Snapshot
+	for _, s := range snapshots {
+		if s.Name != CurrentSnapshot && strings.HasPrefix(s.Name, p.Prefix) {
+			list = append(list, s)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].SnapTime.After(list[j].SnapTime)
+	})
+
+	kept := map[*Snapshot]bool{}
+	marked := map[*Snapshot]bool{}
+	mark := func(n int, period func(t time.Time) string) {
+		if n <= 0 {
+			return
+		}
+		covered := map[string]bool{} // periods with a snapshot kept by an earlier rule
+		for _, s := range list {
+			if kept[s] {
+				covered[period(s.SnapTime)] = true
+			}
+		}
+		selected := map[string]bool{}
+		for _, s := range list {
+			id := period(s.SnapTime)
+			if marked[s] || covered[id] {
+				continue
+			}
+			if selected[id] {
+				marked[s] = true // an older snapshot of a period already kept
+				continue
+			}
+			if len(selected) >= n {
+				break
+			}
+			selected[id] = true
+			marked[s], kept[s] = true, true
+		}
+	}
+	last := 0
+	mark(p.KeepLast, func(time.Time) string { last++; return fmt.Sprint(last) })
+	mark(p.KeepHourly, func(t time.Time) string { return t.Local().Format("2006-01-02 15") })
+	mark(p.KeepDaily, func(t time.Time) string { return t.Local().Format("2006-01-02") })
+	mark(p.KeepWeekly, func(t time.Time) string {
+		year, week := t.Local().ISOWeek()
+		return fmt.Sprintf("%d-%02d", year, week)
+	})
+
+	for _, s := range list {
+		if kept[s] || p.IsEmpty() {
+			keep = append(keep, s)
+		} else {
+			prune = append(prune, s)
+		}
+	}
+	return keep, prune
+}
+
+// PruneFlags returns the flags read by GetPrunePolicy.
+func PruneFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:     "keep-last",
+			Usage:    "keep the `N` newest snapshots",
+			Category: "retention",
+		},
+		&cli.IntFlag{
+			Name:     "keep-hourly",
+			Usage:    "keep the newest snapshot of each of the last `N` hours with snapshots",
+			Category: "retention",
+		},
+		&cli.IntFlag{
+			Name:     "keep-daily",
+			Usage:    "keep the newest snapshot of each of the last `N` days with snapshots",
+			Category: "retention",
+		},
+		&cli.IntFlag{
+			Name:     "keep-weekly",
+			Usage:    "keep the newest snapshot of each of the last `N` weeks with snapshots",
+			Category: "retention",
+		},
+		&cli.StringFlag{
+			Name:     "prefix",
+			Usage:    "only consider snapshots whose name starts with `PREFIX`",
+			Category: "retention",
+		},
+	}
+}
+
+// GetPrunePolicy builds a PrunePolicy from the PruneFlags. A policy without
+// keep rules is an error, rather than deleting every snapshot.
+func GetPrunePolicy(c *cli.Context) (*PrunePolicy, error) {
+	p := &PrunePolicy{
+		KeepLast:   c.Int("keep-last"),
+		KeepHourly: c.Int("keep-hourly"),
+		KeepDaily:  c.Int("keep-daily"),
+		KeepWeekly: c.Int("keep-weekly"),
+		Prefix:     c.String("prefix"),
+	}
+	if p.IsEmpty() {
+		return nil, fmt.Errorf("give at least one of --keep-last, --keep-hourly, --keep-daily or --keep-weekly")
+	}
+	return p, nil
+}
@@ -0,0 +1,192 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// snap makes a snapshot taken at when, a local `2006-01-02 15:04`.
+func snap(t *testing.T, name string, when string) *Snapshot {
+	t.Helper()
+	taken, err := time.ParseInLocation("2006-01-02 15:04", when, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Snapshot{Name: name, SnapTime: taken}
+}
+
+func snapshotNames(list []*Snapshot) []string {
+	names := []string{}
+	for _, s := range list {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestPrunePolicySelect(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    PrunePolicy
+		snapshots [][2]string // name, time
+		keep      []string
+		prune     []string
+	}{
+		{
+			name:   "keep-last",
+			policy: PrunePolicy{KeepLast: 2},
+			snapshots: [][2]string{
+				{"a", "2026-01-05 10:00"}, {"c", "2026-01-05 12:00"},
+				{"b", "2026-01-05 11:00"}, {"d", "2026-01-05 13:00"},
+			},
+			keep:  []string{"d", "c"},
+			prune: []string{"b", "a"},
+		},
+		{
+			name:   "keep-last more than there are",
+			policy: PrunePolicy{KeepLast: 5},
+			snapshots: [][2]string{
+				{"a", "2026-01-05 10:00"}, {"b", "2026-01-05 11:00"},
+			},
+			keep:  []string{"b", "a"},
+			prune: []string{},
+		},
+		{
+			name:   "keep-hourly",
+			policy: PrunePolicy{KeepHourly: 2},
+			snapshots: [][2]string{
+				{"h10a", "2026-01-05 10:10"}, {"h10b", "2026-01-05 10:50"},
+				{"h11", "2026-01-05 11:20"},
+				{"h12a", "2026-01-05 12:05"}, {"h12b", "2026-01-05 12:40"},
+			},
+			keep:  []string{"h12b", "h11"},
+			prune: []string{"h12a", "h10b", "h10a"},
+		},
+		{
+			name:   "keep-daily",
+			policy: PrunePolicy{KeepDaily: 2},
+			snapshots: [][2]string{
+				{"d5a", "2026-01-05 09:00"}, {"d5b", "2026-01-05 18:00"},
+				{"d6", "2026-01-06 08:00"},
+				{"d7a", "2026-01-07 07:00"}, {"d7b", "2026-01-07 20:00"},
+			},
+			keep:  []string{"d7b", "d6"},
+			prune: []string{"d7a", "d5b", "d5a"},
+		},
+		{
+			name:   "keep-weekly",
+			policy: PrunePolicy{KeepWeekly: 2},
+			snapshots: [][2]string{
+				// 2026-01-05 and 2026-01-12 are Mondays
+				{"w1", "2026-01-04 12:00"},
+				{"w2a", "2026-01-05 12:00"}, {"w2b", "2026-01-07 12:00"},
+				{"w3", "2026-01-12 12:00"},
+			},
+			keep:  []string{"w3", "w2b"},
+			prune: []string{"w2a", "w1"},
+		},
+		{
+			name:   "keep-last and keep-daily count different days",
+			policy: PrunePolicy{KeepLast: 1, KeepDaily: 2},
+			snapshots: [][2]string{
+				{"d5", "2026-01-05 18:00"}, {"d6", "2026-01-06 08:00"},
+				{"d7a", "2026-01-07 07:00"}, {"d7b", "2026-01-07 20:00"},
+			},
+			keep:  []string{"d7b", "d6", "d5"},
+			prune: []string{"d7a"},
+		},
+		{
+			name:   "overlapping hourly and daily buckets",
+			policy: PrunePolicy{KeepHourly: 1, KeepDaily: 2},
+			snapshots: [][2]string{
+				{"d5", "2026-01-05 10:00"}, {"d6", "2026-01-06 10:00"},
+				{"d7a", "2026-01-07 09:00"}, {"d7b", "2026-01-07 20:10"}, {"d7c", "2026-01-07 20:30"},
+			},
+			keep:  []string{"d7c", "d6", "d5"},
+			prune: []string{"d7b", "d7a"},
+		},
+		{
+			name:   "all rules",
+			policy: PrunePolicy{KeepLast: 1, KeepHourly: 1, KeepDaily: 1, KeepWeekly: 2},
+			snapshots: [][2]string{
+				{"w1", "2026-01-02 12:00"},
+				{"w2", "2026-01-09 12:00"},
+				{"h10", "2026-01-12 10:00"}, {"h11a", "2026-01-12 11:00"}, {"h11b", "2026-01-12 11:30"},
+			},
+			// keep-last: h11b, keep-hourly: h10, keep-daily: none left as the
+			// day is covered, keep-weekly: w2 and w1
+			keep:  []string{"h11b", "h10", "w2", "w1"},
+			prune: []string{"h11a"},
+		},
+		{
+			name:   "prefix",
+			policy: PrunePolicy{KeepLast: 1, Prefix: "auto-"},
+			snapshots: [][2]string{
+				{"auto-1", "2026-01-05 10:00"}, {"manual", "2026-01-05 11:00"},
+				{"auto-2", "2026-01-05 12:00"}, {"before-upgrade", "2026-01-05 13:00"},
+			},
+			keep:  []string{"auto-2"},
+			prune: []string{"auto-1"},
+		},
+		{
+			name:   "current is never considered",
+			policy: PrunePolicy{KeepLast: 1},
+			snapshots: [][2]string{
+				{"a", "2026-01-05 10:00"}, {"b", "2026-01-05 11:00"}, {CurrentSnapshot, "2026-01-05 12:00"},
+			},
+			keep:  []string{"b"},
+			prune: []string{"a"},
+		},
+		{
+			name:   "empty policy keeps everything",
+			policy: PrunePolicy{},
+			snapshots: [][2]string{
+				{"a", "2026-01-05 10:00"}, {"b", "2026-01-06 11:00"}, {CurrentSnapshot, "2026-01-06 12:00"},
+			},
+			keep:  []string{"b", "a"},
+			prune: []string{},
+		},
+		{
+			name:      "no snapshots",
+			policy:    PrunePolicy{KeepLast: 3},
+			snapshots: nil,
+			keep:      []string{},
+			prune:     []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var snapshots []*Snapshot
+			for _, s := range tt.snapshots {
+				snapshots = append(snapshots, snap(t, s[0], s[1]))
+			}
+			keep, prune := tt.policy.Select(snapshots)
+			if got := snapshotNames(keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("keep = %v, want %v", got, tt.keep)
+			}
+			if got := snapshotNames(prune); !reflect.DeepEqual(got, tt.prune) {
+				t.Errorf("prune = %v, want %v", got, tt.prune)
+			}
+		})
+	}
+}
+
+func TestPrunePolicyIsEmpty(t *testing.T) {
+	tests := []struct {
+		policy PrunePolicy
+		want   bool
+	}{
+		{PrunePolicy{}, true},
+		{PrunePolicy{Prefix: "auto-"}, true},
+		{PrunePolicy{KeepLast: -1}, true},
+		{PrunePolicy{KeepLast: 1}, false},
+		{PrunePolicy{KeepHourly: 1}, false},
+		{PrunePolicy{KeepDaily: 1}, false},
+		{PrunePolicy{KeepWeekly: 1}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.IsEmpty(); got != tt.want {
+			t.Errorf("%+v.IsEmpty() = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+)
+
+// CurrentSnapshot is the name of the pseudo-snapshot for a guest's current
+// state in the API's snapshot list.
+const CurrentSnapshot = "current"
+
+// Snapshot is a snapshot of a QEMU VM or LXC container.
+type Snapshot struct {
+	Name        string    `json:"name"`
+	Parent      string    `json:"parent,omitempty"`
+	Description string    `json:"description"`
+	SnapTime    time.Time `json:"snaptime" table:"Taken"`
+	VMState     bool      `json:"vmstate" table:"RAM"` // whether the RAM was saved (QEMU only)
+	Current     bool      `json:"current"`             // whether the guest's current state derives from it
+}
+
+// ListSnapshots returns the snapshots of the guest described by rs, oldest
+// first. The CurrentSnapshot is left out; its parent is marked Current.
+func ListSnapshots(ctx context.Context, client proxmox.Client, rs *proxmox.ClusterResource) ([]*Snapshot, error) {
+	var list []*proxmox.Snapshot
+	if err := client.Get(ctx, GuestPath(rs)+"/snapshot", &list); err != nil {
+		return nil, WrapApiError(err)
+	}
+	var current string
+	snapshots := []*Snapshot{}
+	for _, s := range list {
+		if s.Name == CurrentSnapshot {
+			current = s.Parent
+			continue
+		}
+		snapshots = append(snapshots, &Snapshot{
+			Name:        s.Name,
+			Parent:      s.Parent,
+			Description: s.Description,
+			SnapTime:    time.Unix(s.Snaptime, 0),
+			VMState:     s.Vmstate != 0,
+		})
+	}
+	for _, s := range snapshots {
+		s.Current = s.Name == current
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].SnapTime.Before(snapshots[j].SnapTime)
+	})
+	return snapshots, nil
+}
+
+// CreateSnapshot starts taking a snapshot of the guest described by rs.
+// Saving the RAM with vmstate is only possible for QEMU VMs.
+func CreateSnapshot(
+	ctx context.Context,
+	client *proxmox.Client,
+	rs *proxmox.ClusterResource,
+	name string,
+	description string,
+	vmstate bool,
+) (*proxmox.Task, error) {
+	options := map[string]string{"snapname": name}
+	if description != "" {
+		options["description"] = description
+	}
+	if vmstate {
+		if rs.Type != QemuResource {
+			return nil, fmt.Errorf("guest %d is an %s container, only QEMU VMs can save their RAM", rs.VMID, rs.Type)
+		}
+		options["vmstate"] = "1"
+	}
+	var upid proxmox.UPID
+	if err := client.Post(ctx, GuestPath(rs)+"/snapshot", options, &upid); err != nil {
+		return nil, WrapApiError(err)
+	}
+	return newSnapshotTask(upid, client)
+}
+
+// RollbackSnapshot starts rolling the guest described by rs back to the
+// snapshot name.
+func RollbackSnapshot(
+	ctx context.Context,
+	client *proxmox.Client,
+	rs *proxmox.ClusterResource,
+	name string,
+) (*proxmox.Task, error) {
+	var upid proxmox.UPID
+	path := fmt.Sprintf("%s/snapshot/%s/rollback", GuestPath(rs), url.PathEscape(name))
+	if err := client.Post(ctx, path, nil, &upid); err != nil {
+		return nil, WrapApiError(err)
+	}
+	return newSnapshotTask(upid, client)
+}
+
+// DeleteSnapshot starts deleting the snapshot name of the guest described by
+// rs. With force, the snapshot is removed from the config even if removing
+// its disk snapshots fails.
+func DeleteSnapshot(
+	ctx context.Context,
+	client *proxmox.Client,
+	rs *proxmox.ClusterResource,
+	name string,
+	force bool,
+) (*proxmox.Task, error) {
+	var upid proxmox.UPID
+	path := fmt.Sprintf("%s/snapshot/%s", GuestPath(rs), url.PathEscape(name))
+	if force {
+		path += "?force=1"
+	}
+	if err := client.Delete(ctx, path, &upid); err != nil {
+		return nil, WrapApiError(err)
+	}
+	return newSnapshotTask(upid, client)
+}
+
+// GetSnapshotConfig returns the guest configuration saved in the snapshot
+// name of the guest described by rs.
+func GetSnapshotConfig(
+	ctx context.Context,
+	client proxmox.Client,
+	rs *proxmox.ClusterResource,
+	name string,
+) (map[string]interface{}, error) {
+	var raw map[string]json.RawMessage
+	path := fmt.Sprintf("%s/snapshot/%s/config", GuestPath(rs), url.PathEscape(name))
+	if err := client.Get(ctx, path, &raw); err != nil {
+		return nil, WrapApiError(err)
+	}
+	config := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber() // keep numbers as written instead of float64
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				value = i
+			} else if f, err := n.Float64(); err == nil {
+				value = f
+			}
+		}
+		config[k] = value
+	}
+	return config, nil
+}
+
+func newSnapshotTask(upid proxmox.UPID, client *proxmox.Client) (*proxmox.Task, error) {
+	task := proxmox.NewTask(upid, client)
+	if task == nil {
+		return nil, fmt.Errorf("no task was started")
+	}
+	return task, nil
+}
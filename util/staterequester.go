@@ -3,22 +3,32 @@ package util
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/luthermonson/go-proxmox"
 	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
 )
 
 type RequestableState string
 
 const (
-	RunningState = RequestableState(proxmox.StatusVirtualMachineRunning)
-	StoppedState = RequestableState(proxmox.StatusVirtualMachineStopped)
-	PausedState  = RequestableState(proxmox.StatusVirtualMachinePaused)
+	RunningState    = RequestableState(proxmox.StatusVirtualMachineRunning)
+	StoppedState    = RequestableState(proxmox.StatusVirtualMachineStopped)
+	PausedState     = RequestableState(proxmox.StatusVirtualMachinePaused)
+	ShutdownState   = RequestableState("shutdown")   // stopped by the guest OS (ACPI)
+	RebootState     = RequestableState("reboot")     // rebooted by the guest OS (ACPI)
+	ResetState      = RequestableState("reset")      // hard reset
+	HibernatedState = RequestableState("hibernated") // suspended to disk
+	ResumedState    = RequestableState("resumed")    // running again after a suspend
 )
 
 type StateRequestParams struct {
 	RequestedState RequestableState
 	Vm             *proxmox.VirtualMachine
+	Client         *proxmox.Client // needed for ShutdownState with Timeout or ForceStop
+	Timeout        uint64          // ShutdownState: seconds to wait for the guest OS
+	ForceStop      bool            // ShutdownState: stop the VM if it hasn't shut down by Timeout
 }
 
 // RequestState requests Proxmox change the state of a virtual machine.
@@ -27,15 +37,171 @@ func RequestState(ctx context.Context, params StateRequestParams) (*proxmox.Task
 	var task *proxmox.Task
 	var err error
 
+	vm := params.Vm
 	switch params.RequestedState {
 	case RunningState:
-		task, err = params.Vm.Start(ctx)
+		task, err = vm.Start(ctx)
 	case StoppedState:
-		task, err = params.Vm.Stop(ctx)
+		task, err = vm.Stop(ctx)
 	case PausedState:
-		task, err = params.Vm.Pause(ctx)
+		task, err = vm.Pause(ctx)
+	case ShutdownState:
+		task, err = shutdown(ctx, params)
+	case RebootState:
+		task, err = vm.Reboot(ctx)
+	case ResetState:
+		task, err = vm.Reset(ctx)
+	case HibernatedState:
+		task, err = vm.Hibernate(ctx)
+	case ResumedState:
+		if vm.IsHibernated() {
+			task, err = vm.Start(ctx) // starting resumes from the saved state
+		} else {
+			task, err = vm.Resume(ctx)
+		}
+	default:
+		return nil, fmt.Errorf("unknown state %s", params.RequestedState)
+	}
+	if err != nil {
+		return nil, WrapApiError(err)
+	}
+	if task == nil {
+		return nil, fmt.Errorf("no task was started for VM %d", vm.VMID)
+	}
+	logrus.Infof("State %s requested! (vm: %d, task: %s)\n", params.RequestedState, vm.VMID, task.UPID)
+	return task, nil
+}
+
+// shutdown asks the guest OS to shut down, with the optional timeout and
+// force-stop fallback, which proxmox.VirtualMachine.Shutdown doesn't support.
+func shutdown(ctx context.Context, params StateRequestParams) (*proxmox.Task, error) {
+	vm := params.Vm
+	if params.Timeout == 0 && !params.ForceStop {
+		return vm.Shutdown(ctx)
+	}
+	if params.Client == nil {
+		return nil, fmt.Errorf("shutdown options need a client")
+	}
+	options := map[string]string{}
+	if params.Timeout > 0 {
+		options["timeout"] = strconv.FormatUint(params.Timeout, 10)
+	}
+	if params.ForceStop {
+		options["forceStop"] = "1"
+	}
+	var upid proxmox.UPID
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/status/shutdown", vm.Node, vm.VMID)
+	if err := params.Client.Post(ctx, path, options, &upid); err != nil {
+		return nil, WrapApiError(err)
+	}
+	return proxmox.NewTask(upid, params.Client), nil
+}
+
+// DescribeState returns a VM's state as the RequestableState it is in.
+func DescribeState(vm *proxmox.VirtualMachine) string {
+	switch {
+	case vm.IsHibernated():
+		return string(HibernatedState)
+	case vm.IsPaused():
+		return string(PausedState)
+	}
+	return vm.Status
+}
+
+// InRequestedState reports whether vm is already in state, so requesting it
+// would change nothing. Reboots and resets always change something.
+func InRequestedState(vm *proxmox.VirtualMachine, state RequestableState) bool {
+	switch state {
+	case RunningState, ResumedState:
+		return vm.IsRunning()
+	case StoppedState, ShutdownState:
+		return vm.IsStopped()
+	case PausedState:
+		return vm.IsPaused()
+	case HibernatedState:
+		return vm.IsHibernated()
+	}
+	return false
+}
+
+// CheckStateRequest returns an error if state can't be reached from the
+// state vm is in.
+func CheckStateRequest(vm *proxmox.VirtualMachine, state RequestableState) error {
+	switch state {
+	case RebootState, ResetState, PausedState:
+		if !vm.IsRunning() {
+			return fmt.Errorf("VM %d is %s, it must be running to %s", vm.VMID, DescribeState(vm), state)
+		}
+	case HibernatedState:
+		if !vm.IsRunning() && !vm.IsPaused() {
+			return fmt.Errorf("VM %d is %s, it must be running to hibernate", vm.VMID, DescribeState(vm))
+		}
+	case ResumedState:
+		if !vm.IsPaused() && !vm.IsHibernated() {
+			return fmt.Errorf("VM %d is %s, not suspended; use `gomox start`", vm.VMID, DescribeState(vm))
+		}
+	}
+	return nil
+}
+
+// StateAction returns a GuestAction that requests params.RequestedState.
+// A VM already in that state is an error or, if idempotent, skipped with a
+// warning.
+func StateAction(params StateRequestParams, idempotent bool) GuestAction {
+	return func(ctx context.Context, vm *proxmox.VirtualMachine) (*proxmox.Task, error) {
+		if InRequestedState(vm, params.RequestedState) {
+			msg := fmt.Sprintf("VM %d already in requested state (%s)", vm.VMID, DescribeState(vm))
+			switch idempotent {
+			case true:
+				logrus.Warn(msg)
+				return nil, nil
+			case false:
+				return nil, fmt.Errorf(msg)
+			}
+		}
+		if err := CheckStateRequest(vm, params.RequestedState); err != nil {
+			return nil, err
+		}
+		p := params // the action runs concurrently for several guests
+		p.Vm = vm
+		return RequestState(ctx, p)
+	}
+}
+
+// RequestStateCli requests params.RequestedState for the guests given as
+// arguments, honoring the `--idempotent` and `--parallel` flags. Several
+// guests are handled by RunBulkCli; for a single guest, the task is returned
+// for the caller to finish (nil if there was nothing to do).
+func RequestStateCli(c *cli.Context, params StateRequestParams) (*proxmox.Task, error) {
+	client, err := GetClient(c)
+	if err != nil {
+		return nil, err
+	}
+	guests, err := ResolveGuestArgs(c.Context, client, c.Args().Slice(), false)
+	if err != nil {
+		return nil, err
+	}
+	params.Client = &client
+	action := StateAction(params, c.Bool("idempotent"))
+	if len(guests) > 1 {
+		return nil, RunBulkCli(c, client, guests, action)
+	}
+
+	vm, err := GetVirtualMachine(c.Context, client, guests[0])
+	if err != nil {
+		return nil, err
+	}
+	return action(c.Context, vm)
+}
 
+// StateFlags returns the flags read by RequestStateCli.
+func StateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "idempotent",
+			Usage: "Don't return error if VM is already in requested state",
+			Value: false,
+		},
+		ParallelFlag(),
 	}
-	logrus.Info(fmt.Sprintf("State %s requested! (vm: %d, task: %#v)", params.RequestedState, params.Vm.VMID, task))
-	return task, err
 }
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luthermonson/go-proxmox"
+)
+
+// TestStateActionConcurrent runs a StateAction for many guests at once, the
+// way RunBulk does; run it with -race.
+func TestStateActionConcurrent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /nodes/<node>/qemu/<vmid>/status/shutdown
+		parts := strings.Split(r.URL.Path, "/")
+		vmid := parts[len(parts)-3]
+		mu.Lock()
+		requests[vmid]++
+		mu.Unlock()
+		fmt.Fprintf(w, `{"data":"UPID:pve1:00000001:00000002:00000003:qmshutdown:%s:root@pam:"}`, vmid)
+	}))
+	defer server.Close()
+	client := proxmox.NewClient(server.URL)
+
+	action := StateAction(StateRequestParams{
+		RequestedState: ShutdownState,
+		Client:         client,
+		Timeout:        60,
+	}, false)
+	const n = 50
+	ids := make([]string, n)
+	err := RunParallel(context.Background(), n, 16, func(ctx context.Context, i int) error {
+		vm := &proxmox.VirtualMachine{VMID: proxmox.StringOrUint64(100 + i), Node: "pve1", Status: proxmox.StatusVirtualMachineRunning}
+		task, err := action(ctx, vm)
+		if err != nil {
+			return err
+		}
+		ids[i] = task.ID
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range ids {
+		if want := fmt.Sprint(100 + i); id != want {
+			t.Errorf("guest %s got the task for %s", want, id)
+		}
+	}
+	for vmid, count := range requests {
+		if count != 1 {
+			t.Errorf("guest %s was shut down %d times", vmid, count)
+		}
+	}
+	if len(requests) != n {
+		t.Errorf("%d guests were shut down, want %d", len(requests), n)
+	}
+}
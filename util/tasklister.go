@@ -0,0 +1,280 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/urfave/cli/v2"
+)
+
+// The states of listed tasks, as used by TaskFilter.
+const (
+	TaskStateRunning = "running"
+	TaskStateOk      = "ok"
+	TaskStateFailed  = "failed"
+)
+
+// taskStopped is the status of a finished task; see proxmox.TaskRunning.
+const taskStopped = "stopped"
+
+// DefaultTaskLimit is how many tasks ListTasks returns by default.
+const DefaultTaskLimit = 50
+
+// TaskFilter selects tasks from the task lists. Empty fields match every
+// task.
+type TaskFilter struct {
+	Nodes []string
+	VMID  uint64
+	User  string // a substring of the user, e.g. `root@` or `@pve`
+	Type  string // e.g. qmstart or vzdump
+	State string // TaskStateRunning, TaskStateOk or TaskStateFailed
+	Since time.Time
+	Until time.Time
+}
+
+// Matches reports whether task passes the filter.
+func (f *TaskFilter) Matches(task *proxmox.Task) bool {
+	switch {
+	case len(f.Nodes) > 0 && !containsString(f.Nodes, task.Node),
+		f.VMID != 0 && task.ID != strconv.FormatUint(f.VMID, 10),
+		f.User != "" && !strings.Contains(task.User, f.User),
+		f.Type != "" && task.Type != f.Type,
+		f.State != "" && TaskState(task) != f.State,
+		!f.Since.IsZero() && task.StartTime.Before(f.Since),
+		!f.Until.IsZero() && task.StartTime.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// TaskState returns whether a listed task is running, ok or failed. Tasks
+// that finished with warnings are ok.
+func TaskState(task *proxmox.Task) string {
+	switch {
+	case task.Status == proxmox.TaskRunning:
+		return TaskStateRunning
+	case task.ExitStatus == "OK", strings.HasPrefix(task.ExitStatus, "WARNINGS"):
+		return TaskStateOk
+	}
+	return TaskStateFailed
+}
+
+// normalizeTask makes a task from a task list look like one updated by
+// proxmox.Task.Ping: the lists leave the status out of running tasks and put
+// the exit status of stopped ones in `status`.
+func normalizeTask(task *proxmox.Task) {
+	switch task.Status {
+	case "", proxmox.TaskRunning:
+		if task.EndTime.IsZero() {
+			task.Status = proxmox.TaskRunning
+			break
+		}
+		task.Status = taskStopped
+	case taskStopped:
+	default:
+		if task.ExitStatus == "" {
+			task.ExitStatus = task.Status
+		}
+		task.Status = taskStopped
+	}
+	task.IsRunning = task.Status == proxmox.TaskRunning
+	task.IsCompleted = !task.IsRunning
+	task.IsSuccessful = task.IsCompleted && task.ExitStatus == "OK"
+	task.IsFailed = task.IsCompleted && !task.IsSuccessful
+}
+
+/*
+ListTasks returns the tasks that pass f, oldest first, and at most limit of
+them (the most recent ones).
+
+Without nodes or a time window in f, the tasks come from cluster/tasks, the
+cluster's recent and running tasks, in a single request. Otherwise each node's
+task history is searched, on all online nodes if f has none.
+*/
+func ListTasks(ctx context.Context, client proxmox.Client, f *TaskFilter, limit int) ([]*proxmox.Task, error) {
+	if limit <= 0 {
+		limit = DefaultTaskLimit
+	}
+	var (
+		list []*proxmox.Task
+		err  error
+	)
+	if len(f.Nodes) == 0 && f.Since.IsZero() && f.Until.IsZero() {
+		err = client.Get(ctx, "/cluster/tasks", &list)
+	} else {
+		list, err = listNodeTasks(ctx, client, f, limit)
+	}
+	if err != nil {
+		return nil, WrapApiError(err)
+	}
+
+	var matched []*proxmox.Task
+	for _, task := range list {
+		normalizeTask(task)
+		if f.Matches(task) {
+			matched = append(matched, task)
+		}
+	}
+	// break ties by UPID, so repeated listings trim the same tasks
+	sort.SliceStable(matched, func(i, j int) bool {
+		if !matched[i].StartTime.Equal(matched[j].StartTime) {
+			return matched[i].StartTime.Before(matched[j].StartTime)
+		}
+		return matched[i].UPID < matched[j].UPID
+	})
+	if len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched, nil
+}
+
+// listNodeTasks searches the task history of f's nodes, filtering on the
+// server as far as the API allows.
+func listNodeTasks(ctx context.Context, client proxmox.Client, f *TaskFilter, limit int) ([]*proxmox.Task, error) {
+	nodes := f.Nodes
+	if len(nodes) == 0 {
+		statuses, err := client.Nodes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range statuses {
+			if ns.Status == "online" {
+				nodes = append(nodes, ns.Node)
+			}
+		}
+	}
+
+	query := url.Values{}
+	query.Set("source", "all")
+	query.Set("limit", strconv.Itoa(limit))
+	if f.VMID != 0 {
+		query.Set("vmid", strconv.FormatUint(f.VMID, 10))
+	}
+	if f.Type != "" {
+		query.Set("typefilter", f.Type)
+	}
+	if f.User != "" {
+		query.Set("userfilter", f.User)
+	}
+	if f.State == TaskStateFailed {
+		query.Set("errors", "1")
+	}
+	if !f.Since.IsZero() {
+		query.Set("since", strconv.FormatInt(f.Since.Unix(), 10))
+	}
+	if !f.Until.IsZero() {
+		query.Set("until", strconv.FormatInt(f.Until.Unix(), 10))
+	}
+
+	var (
+		mu   sync.Mutex
+		list []*proxmox.Task
+	)
+	err := RunParallel(ctx, len(nodes), DefaultParallel, func(ctx context.Context, i int) error {
+		var nodeList []*proxmox.Task
+		path := fmt.Sprintf("/nodes/%s/tasks?%s", nodes[i], query.Encode())
+		if err := client.Get(ctx, path, &nodeList); err != nil {
+			return fmt.Errorf("node %s: %w", nodes[i], err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		list = append(list, nodeList...)
+		return nil
+	})
+	return list, err
+}
+
+// ParseTimeArg parses a point in time given as a duration before now, like
+// `90m` or `24h`, or as a local date and time, like `2006-01-02 15:04`.
+func ParseTimeArg(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use e.g. 2h, 2006-01-02 or 2006-01-02 15:04", s)
+}
+
+// TaskFilterFlags returns the flags read by GetTaskFilter.
+func TaskFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "node",
+			Usage:    "only tasks on `NODE` (repeatable)",
+			Category: "filters",
+		},
+		&cli.Uint64Flag{
+			Name:     "vmid",
+			Usage:    "only tasks for guest `VMID`",
+			Category: "filters",
+		},
+		&cli.StringFlag{
+			Name:     "user",
+			Usage:    "only tasks of users matching `USER`, e.g. root@pam or @pve",
+			Category: "filters",
+		},
+		&cli.StringFlag{
+			Name:     "type",
+			Usage:    "only tasks of `TYPE`, e.g. qmstart or vzdump",
+			Category: "filters",
+		},
+		&cli.StringFlag{
+			Name:     "status",
+			Usage:    "only tasks that are `running|ok|failed`",
+			Category: "filters",
+		},
+		&cli.StringFlag{
+			Name:     "since",
+			Usage:    "only tasks started after `TIME`, e.g. 2h or 2006-01-02 15:04",
+			Category: "filters",
+		},
+		&cli.StringFlag{
+			Name:     "until",
+			Usage:    "only tasks started before `TIME`",
+			Category: "filters",
+		},
+	}
+}
+
+// GetTaskFilter builds a TaskFilter from the TaskFilterFlags.
+func GetTaskFilter(c *cli.Context) (*TaskFilter, error) {
+	f := &TaskFilter{
+		Nodes: c.StringSlice("node"),
+		VMID:  c.Uint64("vmid"),
+		User:  c.String("user"),
+		Type:  c.String("type"),
+		State: c.String("status"),
+	}
+	switch f.State {
+	case "", TaskStateRunning, TaskStateOk, TaskStateFailed:
+	default:
+		return nil, fmt.Errorf("unknown task status %q, use running, ok or failed", f.State)
+	}
+	now := time.Now()
+	for _, flag := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if s := c.String(flag.name); s != "" {
+			t, err := ParseTimeArg(s, now)
+			if err != nil {
+				return nil, fmt.Errorf("--%s: %w", flag.name, err)
+			}
+			*flag.dest = t
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/perchnet/gomox/tasks"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// PollingOptions returns the tasks.PollingOption for the global
+// `--wait-timeout` and `--stop-on-timeout` flags.
+func PollingOptions(c *cli.Context) []tasks.PollingOption {
+	timeout := time.Duration(c.Int("wait-timeout")) * time.Second
+	return []tasks.PollingOption{
+		tasks.WithTimeout(timeout, c.Bool("stop-on-timeout")),
+	}
+}
+
+// SaveTaskLogs writes the complete logs of ts to the file given with the
+// global `--log-file` flag, if any. The logs of several tasks follow each
+// other, each after a `==> UPID <==` header.
+func SaveTaskLogs(c *cli.Context, ts ...*proxmox.Task) error {
+	path := c.String("log-file")
+	if path == "" || len(ts) == 0 {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	for i, task := range ts {
+		if len(ts) > 1 {
+			if i > 0 {
+				fmt.Fprintln(f)
+			}
+			fmt.Fprintf(f, "==> %s <==\n", task.UPID)
+		}
+		if err := tasks.WriteLog(c.Context, f, task); err != nil {
+			return fmt.Errorf("saving the log of task %s: %w", task.UPID, WrapApiError(err))
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	logrus.Infof("Task log saved to %s\n", path)
+	return nil
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/urfave/cli/v2"
+)
+
+const DefaultTfaType = "totp"
+
+// tfaTypes are the second factors PVE accepts as `<type>:<response>`.
+var tfaTypes = []string{"totp", "recovery", "yubico", "webauthn", "u2f"}
+
+// TfaResponse formats a one-time password as a TFA challenge response.
+// Bare codes are TOTP codes, other factors can be given as e.g. `recovery:CODE`.
+func TfaResponse(otp string) string {
+	for _, t := range tfaTypes {
+		if strings.HasPrefix(otp, t+":") {
+			return otp
+		}
+	}
+	return DefaultTfaType + ":" + otp
+}
+
+// CompleteTfa answers the two-factor challenge in res with the `--otp` code,
+// prompting for one if it wasn't supplied.
+func CompleteTfa(c *cli.Context, client *proxmox.Client, res *ticketResponse) (*ticketResponse, error) {
+	otp := strings.TrimSpace(c.String("otp"))
+	if otp == "" {
+		var err error
+		otp, err = PromptLine(fmt.Sprintf("Two-factor code for %s: ", res.Username))
+		if err != nil {
+			return nil, fmt.Errorf("two-factor authentication required, supply --otp/PVE_OTP: %w", err)
+		}
+	}
+	return answerTfaChallenge(c.Context, client, res, otp)
+}
+
+func answerTfaChallenge(
+	ctx context.Context,
+	client *proxmox.Client,
+	challenge *ticketResponse,
+	otp string,
+) (*ticketResponse, error) {
+	res, err := requestTicket(ctx, client, ticketRequest{
+		Username:     challenge.Username,
+		Password:     TfaResponse(otp),
+		TfaChallenge: challenge.Ticket,
+		NewFormat:    1,
+	})
+	if proxmox.IsNotAuthorized(err) {
+		return nil, fmt.Errorf("the two-factor code was rejected")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.NeedTFA != 0 {
+		return nil, fmt.Errorf("the server asked for another second factor")
+	}
+	return res, nil
+}
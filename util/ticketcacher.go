@@ -0,0 +1,378 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	TicketCacheFileName = "tickets.json"
+	TicketLifetime      = 2 * time.Hour    // PVE tickets expire after two hours
+	TicketRenewAfter    = 90 * time.Minute // renew tickets this close to expiry
+	ticketExpiryMargin  = 5 * time.Minute  // don't hand out tickets about to expire
+)
+
+// CachedTicket is an authentication ticket saved between invocations.
+type CachedTicket struct {
+	Username            string    `json:"username"`
+	Ticket              string    `json:"ticket"`
+	CSRFPreventionToken string    `json:"csrf_prevention_token"`
+	Issued              time.Time `json:"issued"`
+}
+
+// Expires returns when the ticket stops being accepted by the server.
+func (t *CachedTicket) Expires() time.Time {
+	return t.Issued.Add(TicketLifetime)
+}
+
+// IsValid reports whether the ticket can still be used.
+func (t *CachedTicket) IsValid() bool {
+	return time.Now().Before(t.Expires().Add(-ticketExpiryMargin))
+}
+
+// NeedsRenewal reports whether the ticket is close enough to expiry that it
+// should be exchanged for a new one.
+func (t *CachedTicket) NeedsRenewal() bool {
+	return time.Now().After(t.Issued.Add(TicketRenewAfter))
+}
+
+// TicketCache holds cached tickets, keyed by TicketCacheKey.
+type TicketCache map[string]*CachedTicket
+
+// TicketCachePath returns the ticket cache file under the user cache dir.
+func TicketCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, ConfigDirName, TicketCacheFileName), nil
+}
+
+// LoadTicketCache reads the ticket cache. A missing file is an empty cache.
+func LoadTicketCache() (TicketCache, error) {
+	tc := TicketCache{}
+	path, err := TicketCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &tc); err != nil {
+		logrus.Debugf("ignoring unreadable ticket cache %s: %s", path, err)
+		return TicketCache{}, nil
+	}
+	return tc, nil
+}
+
+// Save atomically writes the ticket cache, readable only by the owner.
+func (tc TicketCache) Save() error {
+	path, err := TicketCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(tc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), TicketCacheFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// GetUserId returns the `user@realm` the global flags log in as.
+func GetUserId(c *cli.Context) string {
+	user, realm := c.String("pveuser"), c.String("pverealm")
+	if realm == "" || strings.Contains(user, "@") {
+		return user
+	}
+	return user + "@" + realm
+}
+
+// TicketCacheKey identifies the cached ticket for the active profile, API URL, and user.
+func TicketCacheKey(c *cli.Context) string {
+	profileName, _ := GetActiveProfile(c)
+	return strings.Join([]string{profileName, GetPveUrl(c), GetUserId(c)}, "|")
+}
+
+// GetCachedTicket returns the cached ticket for the current connection
+// settings, if one is still valid.
+func GetCachedTicket(c *cli.Context) *CachedTicket {
+	if c.String("pveuser") == "" {
+		return nil
+	}
+	tc, err := LoadTicketCache()
+	if err != nil {
+		logrus.Debugf("could not load ticket cache: %s", err)
+		return nil
+	}
+	ticket, ok := tc[TicketCacheKey(c)]
+	if !ok || !ticket.IsValid() {
+		return nil
+	}
+	return ticket
+}
+
+// ticketRequest is the body of a POST to /access/ticket.
+type ticketRequest struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	Realm        string `json:"realm,omitempty"`
+	Otp          string `json:"otp,omitempty"`
+	TfaChallenge string `json:"tfa-challenge,omitempty"`
+	NewFormat    int    `json:"new-format,omitempty"`
+}
+
+// ticketResponse is a proxmox.Session that may still need a second factor.
+type ticketResponse struct {
+	proxmox.Session
+	NeedTFA int `json:"NeedTFA,omitempty"`
+}
+
+// requestTicket posts a ticket request to /access/ticket.
+func requestTicket(ctx context.Context, client *proxmox.Client, req ticketRequest) (*ticketResponse, error) {
+	var res ticketResponse
+	if err := client.Post(ctx, "/access/ticket", &req, &res); err != nil {
+		return nil, WrapApiError(err)
+	}
+	if res.Ticket == "" {
+		return nil, fmt.Errorf("the server did not return a ticket")
+	}
+	return &res, nil
+}
+
+func newCachedTicket(res *ticketResponse) *CachedTicket {
+	return &CachedTicket{
+		Username:            res.Username,
+		Ticket:              res.Ticket,
+		CSRFPreventionToken: res.CSRFPreventionToken,
+		Issued:              time.Now(),
+	}
+}
+
+// Login logs in with the password from the global flags, answers a
+// two-factor challenge if the server sends one, and caches the ticket.
+func Login(c *cli.Context, httpClient *http.Client) (*CachedTicket, error) {
+	client := InstantiateClient(GetPveUrl(c), proxmox.WithHTTPClient(httpClient))
+	credentials := GetCredentials(c)
+	res, err := requestTicket(c.Context, &client, ticketRequest{
+		Username:  credentials.Username,
+		Password:  credentials.Password,
+		Realm:     credentials.Realm,
+		Otp:       c.String("otp"),
+		NewFormat: 1,
+	})
+	if err == nil && res.NeedTFA != 0 {
+		res, err = CompleteTfa(c, &client, res)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	ticket := newCachedTicket(res)
+	logrus.Debugf("logged in as %s", ticket.Username)
+	storeTicket(c, ticket)
+	return ticket, nil
+}
+
+// renewTicket exchanges a ticket that is still valid for a fresh one.
+func renewTicket(c *cli.Context, httpClient *http.Client, old *CachedTicket) (*CachedTicket, error) {
+	client := InstantiateClient(GetPveUrl(c), proxmox.WithHTTPClient(httpClient))
+	res, err := requestTicket(c.Context, &client, ticketRequest{
+		Username:  old.Username,
+		Password:  old.Ticket,
+		NewFormat: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ticket := newCachedTicket(res)
+	logrus.Debugf("renewed ticket for %s", ticket.Username)
+	storeTicket(c, ticket)
+	return ticket, nil
+}
+
+// storeTicket saves ticket to the cache. Failing to cache isn't fatal.
+func storeTicket(c *cli.Context, ticket *CachedTicket) {
+	tc, err := LoadTicketCache()
+	if err == nil {
+		tc[TicketCacheKey(c)] = ticket
+		err = tc.Save()
+	}
+	if err != nil {
+		logrus.Warnf("could not cache ticket: %s\n", err)
+	}
+}
+
+// ForgetTicket removes the cached ticket for the current connection settings.
+// It reports whether there was a ticket to remove.
+func ForgetTicket(c *cli.Context) (bool, error) {
+	tc, err := LoadTicketCache()
+	if err != nil {
+		return false, err
+	}
+	key := TicketCacheKey(c)
+	if _, ok := tc[key]; !ok {
+		return false, nil
+	}
+	delete(tc, key)
+	return true, tc.Save()
+}
+
+// ForgetAllTickets removes the ticket cache entirely.
+func ForgetAllTickets() error {
+	path, err := TicketCachePath()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// GetSessionTicket returns a usable ticket, reusing the cached one when
+// possible, renewing it when it nears expiry, and logging in with the
+// password when there is no usable ticket.
+func GetSessionTicket(c *cli.Context, httpClient *http.Client) (*CachedTicket, error) {
+	ticket := GetCachedTicket(c)
+	if ticket != nil && !ticket.NeedsRenewal() {
+		logrus.Debugf("reusing cached ticket for %s", ticket.Username)
+		return ticket, nil
+	}
+	if ticket != nil {
+		renewed, err := renewTicket(c, httpClient, ticket)
+		if err == nil {
+			return renewed, nil
+		}
+		logrus.Debugf("could not renew ticket for %s: %s", ticket.Username, err)
+	}
+	if c.String("pvepassword") == "" {
+		if ticket != nil {
+			return ticket, nil // still valid for a little while
+		}
+		return nil, fmt.Errorf("no valid ticket cached for %s, run `gomox login` first", GetUserId(c))
+	}
+	return Login(c, httpClient)
+}
+
+/*
+ticketRefresher is the transport of clients that authenticate with a cached
+ticket, which the server may stop accepting before it expires, e.g. when it
+was revoked or the node was reinstalled. On a 401, it forgets the ticket and,
+if a password was given, logs in again and retries the request, sending the
+new ticket with every later request too. Without a password, the request fails
+with an error saying to run `gomox login`.
+*/
+type ticketRefresher struct {
+	c           *cli.Context
+	loginClient *http.Client // without the refresher, for logging in
+	base        http.RoundTripper
+
+	mu     sync.Mutex
+	ticket *CachedTicket // the ticket requests are sent with
+	err    error         // why the ticket couldn't be replaced
+}
+
+func newTicketRefresher(c *cli.Context, loginClient *http.Client, ticket *CachedTicket) *ticketRefresher {
+	base := loginClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &ticketRefresher{c: c, loginClient: loginClient, base: base, ticket: ticket}
+}
+
+func (t *ticketRefresher) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	ticket := t.ticket
+	t.mu.Unlock()
+	res, err := t.base.RoundTrip(withTicket(req, ticket))
+	if err != nil || res.StatusCode != http.StatusUnauthorized || strings.HasSuffix(req.URL.Path, "/access/ticket") {
+		return res, err
+	}
+	_ = res.Body.Close()
+
+	fresh, err := t.replace(ticket)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("the ticket was replaced, but the request can't be retried")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.base.RoundTrip(withTicket(req, fresh))
+}
+
+// replace returns the ticket that replaces rejected, logging in unless
+// another request already did.
+func (t *ticketRefresher) replace(rejected *CachedTicket) (*CachedTicket, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ticket != rejected || t.err != nil {
+		return t.ticket, t.err
+	}
+	logrus.Debugf("the server rejected the cached ticket for %s", rejected.Username)
+	if _, err := ForgetTicket(t.c); err != nil {
+		logrus.Debugf("could not forget the rejected ticket: %s", err)
+	}
+	if t.c.String("pvepassword") == "" {
+		t.err = fmt.Errorf("the cached ticket for %s is no longer accepted, run `gomox login` again", GetUserId(t.c))
+		return nil, t.err
+	}
+	fresh, err := Login(t.c, t.loginClient)
+	if err != nil {
+		t.err = err
+		return nil, err
+	}
+	t.ticket = fresh
+	return fresh, nil
+}
+
+// withTicket returns a copy of req authenticated with ticket.
+func withTicket(req *http.Request, ticket *CachedTicket) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("Cookie", "PVEAuthCookie="+ticket.Ticket)
+	req.Header.Set("CSRFPreventionToken", ticket.CSRFPreventionToken)
+	return req
+}
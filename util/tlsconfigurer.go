@@ -0,0 +1,124 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// TlsParams holds the certificate verification settings for the API connection.
+type TlsParams struct {
+	Insecure    bool   // skip certificate verification entirely
+	CaFile      string // PEM bundle of additional trusted CAs
+	Fingerprint string // SHA-256 pin of the node certificate
+}
+
+// GetTlsParams reads the TLS verification flags.
+func GetTlsParams(c *cli.Context) TlsParams {
+	return TlsParams{
+		Insecure:    c.Bool("insecure"),
+		CaFile:      c.String("ca-file"),
+		Fingerprint: c.String("fingerprint"),
+	}
+}
+
+// NormalizeFingerprint converts a SHA-256 fingerprint to the colon-separated
+// uppercase form printed by `openssl x509 -fingerprint -sha256` and the PVE web UI.
+// Fingerprints without separators are also accepted.
+func NormalizeFingerprint(fingerprint string) (string, error) {
+	fp := strings.ToUpper(strings.TrimSpace(fingerprint))
+	fp = strings.TrimPrefix(fp, "SHA256:")
+	fp = strings.NewReplacer(":", "", " ", "").Replace(fp)
+	raw, err := hex.DecodeString(fp)
+	if err != nil || len(raw) != sha256.Size {
+		return "", fmt.Errorf(
+			"invalid fingerprint %q: expected a SHA-256 fingerprint like AB:CD:...:EF (%d bytes)",
+			fingerprint, sha256.Size,
+		)
+	}
+	return formatFingerprint(raw), nil
+}
+
+func formatFingerprint(raw []byte) string {
+	parts := make([]string, len(raw))
+	for i, b := range raw {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a certificate.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return formatFingerprint(sum[:])
+}
+
+// GetTlsConfig builds the *tls.Config described by params.
+// When a fingerprint is pinned, the node certificate is checked against it on
+// every connection (including resumed sessions) instead of the system roots.
+func GetTlsConfig(params TlsParams) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: params.Insecure,
+	}
+
+	if params.CaFile != "" {
+		pem, err := os.ReadFile(params.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", params.CaFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if params.Fingerprint != "" {
+		expected, err := NormalizeFingerprint(params.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		// the pin replaces chain verification, which would reject the
+		// self-signed certificate of a fresh install
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("the server presented no certificate")
+			}
+			actual := CertificateFingerprint(state.PeerCertificates[0])
+			if actual != expected {
+				return fmt.Errorf(
+					"certificate fingerprint mismatch!\n"+
+						"    expected: %s\n"+
+						"    received: %s\n"+
+						"The node certificate has changed, or something is intercepting the connection.",
+					expected, actual,
+				)
+			}
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+// GetHttpClient returns an *http.Client using the TLS settings from the global flags.
+func GetHttpClient(c *cli.Context) (*http.Client, error) {
+	tlsConfig, err := GetTlsConfig(GetTlsParams(c))
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
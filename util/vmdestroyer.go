@@ -7,16 +7,17 @@ import (
 	"github.com/perchnet/gomox/tasks"
 	"github.com/luthermonson/go-proxmox"
 	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
 )
 
 func DestroyVm(ctx context.Context, vm *proxmox.VirtualMachine) (proxmox.Task, error) {
 	task, err := vm.Delete(ctx)
 	if err != nil {
-		return *task, err
+		return proxmox.Task{}, WrapApiError(err)
 	}
 	err = task.Ping(ctx)
 	if err != nil {
-		return *task, err
+		return *task, WrapApiError(err)
 	}
 	logrus.Debugf("deletion requested! %#v", task)
 	return *task, nil
@@ -35,13 +36,9 @@ func DestroyVmWithForce(ctx context.Context, vm *proxmox.VirtualMachine) (proxmo
 		)
 		task, err := vm.Stop(ctx)
 		if err != nil {
-			return *task, err
+			return proxmox.Task{}, err
 		}
-		err = tasks.WaitTask(
-			ctx,
-			task,
-		)
-		if err != nil {
+		if err := waitStopped(ctx, task); err != nil {
 			return *task, err
 		}
 	}
@@ -52,3 +49,93 @@ func DestroyVmWithForce(ctx context.Context, vm *proxmox.VirtualMachine) (proxmo
 	logrus.Info(fmt.Sprintf("deletion requested! %#v", task))
 	return task, err
 }
+
+// DestroyGuest destroys the QEMU VM or LXC container described by rs.
+func DestroyGuest(ctx context.Context, client *proxmox.Client, rs *proxmox.ClusterResource) (*proxmox.Task, error) {
+	var upid proxmox.UPID
+	if err := client.Delete(ctx, GuestPath(rs), &upid); err != nil {
+		return nil, WrapApiError(err)
+	}
+	task := proxmox.NewTask(upid, client)
+	if task == nil {
+		return nil, fmt.Errorf("no task was started to destroy guest %d", rs.VMID)
+	}
+	return task, nil
+}
+
+// DestroyGuestWithForce is DestroyGuest, stopping the guest first if it is
+// running.
+func DestroyGuestWithForce(ctx context.Context, client *proxmox.Client, rs *proxmox.ClusterResource) (*proxmox.Task, error) {
+	if rs.Status == proxmox.StatusVirtualMachineRunning {
+		logrus.Warnf("The guest %d was running!\nStopping before destroying.\n", rs.VMID)
+		var upid proxmox.UPID
+		if err := client.Post(ctx, GuestPath(rs)+"/status/stop", nil, &upid); err != nil {
+			return nil, WrapApiError(err)
+		}
+		task := proxmox.NewTask(upid, client)
+		if task == nil {
+			return nil, fmt.Errorf("no task was started to stop guest %d", rs.VMID)
+		}
+		if err := waitStopped(ctx, task); err != nil {
+			return nil, err
+		}
+	}
+	return DestroyGuest(ctx, client, rs)
+}
+
+// waitStopped waits for the stop task of a forced destroy, and returns a
+// tasks.TaskFailedError if the guest couldn't be stopped.
+func waitStopped(ctx context.Context, task *proxmox.Task) error {
+	if err := tasks.WaitTask(ctx, task); err != nil {
+		return err
+	}
+	if err := task.Ping(ctx); err != nil {
+		return WrapApiError(err)
+	}
+	return tasks.CheckFailed(task)
+}
+
+// OverwriteGuest makes room for a new guest with vmid, as clone and restore
+// do when given a VMID: if a guest with vmid exists, it is destroyed with
+// DestroyGuestWithForce when `--overwrite` is set, and an error otherwise.
+// It waits for the destroy task and returns it, or nil if there was no guest.
+func OverwriteGuest(c *cli.Context, client *proxmox.Client, vmid uint64) (*proxmox.Task, error) {
+	guests, err := GetGuestList(c.Context, *client)
+	if err != nil {
+		return nil, err
+	}
+	var existing *proxmox.ClusterResource
+	for _, rs := range guests {
+		if rs.VMID == vmid {
+			existing = rs
+		}
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	logrus.Infof("Guest with target ID %d already exists.\n", vmid)
+	if !c.Bool("overwrite") {
+		logrus.Tracef("%#v\n", existing)
+		return nil, fmt.Errorf("Use --overwrite if necessary.\n")
+	}
+
+	logrus.Info("overwrite requested\n")
+	task, err := DestroyGuestWithForce(c.Context, client, existing)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Warnf("destroying guest %d (%s)...\n", existing.VMID, existing.Name)
+	logrus.Debugf("task: %s\n", task.UPID)
+
+	opts := []tasks.WaitOption{tasks.WithPolling(PollingOptions(c)...)}
+	if !c.Bool("quiet") {
+		opts = append(opts, tasks.WithSpinner())
+	}
+	if err := tasks.WaitTask(c.Context, task, opts...); err != nil {
+		return task, err
+	}
+	if err := task.Ping(c.Context); err != nil {
+		return task, WrapApiError(err)
+	}
+	return task, tasks.CheckFailed(task)
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luthermonson/go-proxmox"
+	"github.com/urfave/cli/v2"
+)
+
+// The backup modes of vzdump.
+const (
+	BackupModeSnapshot = "snapshot"
+	BackupModeSuspend  = "suspend"
+	BackupModeStop     = "stop"
+)
+
+// BackupCompressions are the values vzdump accepts for `compress`.
+var BackupCompressions = []string{"0", "1", "gzip", "lzo", "zstd"}
+
+// VzdumpOptions are the settings of a backup. Empty fields use the defaults
+// of the node's vzdump.conf and the storage.
+type VzdumpOptions struct {
+	Storage       string
+	Mode          string
+	Compress      string
+	NotesTemplate string // e.g. `{{guestname}} on {{node}}`
+	Protected     bool
+}
+
+// StartBackup starts a vzdump task on node that backs up the guests vmids
+// one after another.
+func StartBackup(
+	ctx context.Context,
+	client *proxmox.Client,
+	node string,
+	vmids []uint64,
+	opts VzdumpOptions,
+) (*proxmox.Task, error) {
+	ids := make([]string, len(vmids))
+	for i, vmid := range vmids {
+		ids[i] = strconv.FormatUint(vmid, 10)
+	}
+	params := map[string]string{"vmid": strings.Join(ids, ",")}
+	if opts.Storage != "" {
+		params["storage"] = opts.Storage
+	}
+	if opts.Mode != "" {
+		params["mode"] = opts.Mode
+	}
+	if opts.Compress != "" {
+		params["compress"] = opts.Compress
+	}
+	if opts.NotesTemplate != "" {
+		params["notes-template"] = opts.NotesTemplate
+	}
+	if opts.Protected {
+		params["protected"] = "1"
+	}
+
+	var upid proxmox.UPID
+	if err := client.Post(ctx, fmt.Sprintf("/nodes/%s/vzdump", node), params, &upid); err != nil {
+		return nil, WrapApiError(err)
+	}
+	task := proxmox.NewTask(upid, client)
+	if task == nil {
+		return nil, fmt.Errorf("no backup task was started on node %s", node)
+	}
+	return task, nil
+}
+
+// VzdumpFlags returns the flags read by GetVzdumpOptions.
+func VzdumpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "storage",
+			Usage: "store the backups on `STORAGE`",
+		},
+		&cli.StringFlag{
+			Name:  "mode",
+			Usage: "back up running guests in `MODE`: snapshot, suspend or stop",
+		},
+		&cli.StringFlag{
+			Name:  "compress",
+			Usage: "compress with `ALGORITHM`: " + strings.Join(BackupCompressions, ", "),
+		},
+		&cli.StringFlag{
+			Name:  "notes-template",
+			Usage: "add notes from `TEMPLATE`, e.g. '{{guestname}} ({{vmid}}) on {{node}}'",
+		},
+		&cli.BoolFlag{
+			Name:  "protected",
+			Usage: "protect the backups from pruning and removal",
+		},
+	}
+}
+
+// GetVzdumpOptions builds VzdumpOptions from the VzdumpFlags.
+func GetVzdumpOptions(c *cli.Context) (VzdumpOptions, error) {
+	opts := VzdumpOptions{
+		Storage:       c.String("storage"),
+		Mode:          c.String("mode"),
+		Compress:      c.String("compress"),
+		NotesTemplate: c.String("notes-template"),
+		Protected:     c.Bool("protected"),
+	}
+	switch opts.Mode {
+	case "", BackupModeSnapshot, BackupModeSuspend, BackupModeStop:
+	default:
+		return opts, fmt.Errorf("unknown backup mode %q, use snapshot, suspend or stop", opts.Mode)
+	}
+	if opts.Compress != "" && !containsString(BackupCompressions, opts.Compress) {
+		return opts, fmt.Errorf("unknown compression %q, use one of: %s",
+			opts.Compress, strings.Join(BackupCompressions, ", "))
+	}
+	return opts, nil
+}